@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kralicky/klog-inator/pkg/inator"
+	"github.com/spf13/cobra"
+)
+
+var cacheList bool
+var cacheUnusedFor time.Duration
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect or clear the search cache",
+	Long: `The search cache stores the statements found in each file by search --cache,
+keyed by the content of the file. Entries never go stale, but accumulate as
+files change; use "cache clear" to remove them.`,
+}
+
+var cacheInfoCmd = &cobra.Command{
+	Use:   "info",
+	Args:  cobra.NoArgs,
+	Short: "Show the location and size of the search cache",
+	Run: func(cmd *cobra.Command, args []string) {
+		cache, err := openCache()
+		if err != nil {
+			log.Fatal(err)
+		}
+		entries, err := cache.Entries()
+		if err != nil {
+			log.Fatal(err)
+		}
+		var size int64
+		for _, e := range entries {
+			size += e.Size
+			if cacheList {
+				fmt.Printf("%s %s (%s) %d statements, last used %s\n",
+					e.Key[:12], e.File, e.Package, e.NumStatements, e.LastUsed.Format(time.RFC3339))
+			}
+		}
+		fmt.Printf("=> %s: %d entries, %d bytes\n", cache.Dir(), len(entries), size)
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Args:  cobra.NoArgs,
+	Short: "Remove entries from the search cache",
+	Run: func(cmd *cobra.Command, args []string) {
+		cache, err := openCache()
+		if err != nil {
+			log.Fatal(err)
+		}
+		removed, err := cache.Clear(cacheUnusedFor)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("=> Removed %d entries from %s\n", removed, cache.Dir())
+	},
+}
+
+// openCache opens the cache in --cache-dir, or the default cache directory.
+func openCache() (*inator.Cache, error) {
+	dir := cacheDir
+	if dir == "" {
+		var err error
+		if dir, err = inator.DefaultCacheDir(); err != nil {
+			return nil, err
+		}
+	}
+	return inator.OpenCache(dir)
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheInfoCmd, cacheClearCmd)
+	cacheCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "Directory of the search cache (defaults to klog-inator in the user cache directory)")
+	cacheInfoCmd.Flags().BoolVar(&cacheList, "list", false, "List every entry")
+	cacheClearCmd.Flags().DurationVar(&cacheUnusedFor, "unused-for", 0, "Only remove entries which were not used for this long (e.g. 168h)")
+}
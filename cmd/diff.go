@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/kralicky/klog-inator/pkg/inator"
+	"github.com/spf13/cobra"
+)
+
+var minSimilarity float64
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff old-search-list new-search-list",
+	Args:  cobra.ExactArgs(2),
+	Short: "Compare two search lists, e.g. from different releases",
+	Run: func(cmd *cobra.Command, args []string) {
+		oldList, err := inator.LoadSearchList(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		newList, err := inator.LoadSearchList(args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		diff := inator.DiffSearchLists(oldList, newList, inator.WithMinSimilarity(minSimilarity))
+
+		printJson, _ := cmd.Flags().GetBool("json")
+		if printJson {
+			data, _ := json.Marshal(diff)
+			fmt.Println(string(data))
+			return
+		}
+		for _, change := range diff.Changes {
+			printChange(change)
+		}
+		fmt.Printf("=> %d added, %d removed, %d moved, %d changed severity, %d changed verbosity, %d reworded, %d unchanged\n",
+			diff.Count(inator.ChangeAdded),
+			diff.Count(inator.ChangeRemoved),
+			diff.Count(inator.ChangeMoved),
+			diff.Count(inator.ChangeSeverity),
+			diff.Count(inator.ChangeVerbosity),
+			diff.Count(inator.ChangeReworded),
+			diff.NumUnchanged)
+	},
+}
+
+func printChange(change inator.StatementChange) {
+	location := func(s *inator.LogStatement) string {
+		loc := fmt.Sprintf("%s:%d", s.SourceFile, s.LineNumber)
+		if s.Function != "" {
+			loc += " (" + s.Function + ")"
+		}
+		return loc
+	}
+	switch {
+	case change.Old == nil:
+		fmt.Printf("+ [%s] %s: %s\n", change.New.Severity, location(change.New), change.New.FormatString)
+		return
+	case change.New == nil:
+		fmt.Printf("- [%s] %s: %s\n", change.Old.Severity, location(change.Old), change.Old.FormatString)
+		return
+	}
+	var details []string
+	for _, kind := range change.Kinds {
+		switch kind {
+		case inator.ChangeMoved:
+			details = append(details, "moved from "+location(change.Old))
+		case inator.ChangeSeverity:
+			details = append(details, fmt.Sprintf("severity %s -> %s", change.Old.Severity, change.New.Severity))
+		case inator.ChangeVerbosity:
+			details = append(details, fmt.Sprintf("verbosity %s -> %s", change.Old.VerbosityString(), change.New.VerbosityString()))
+		case inator.ChangeReworded:
+			details = append(details, fmt.Sprintf("reworded from %s", change.Old.FormatString))
+		}
+	}
+	fmt.Printf("~ [%s] %s: %s\n", change.New.Severity, location(change.New), change.New.FormatString)
+	for _, detail := range details {
+		fmt.Printf("    %s\n", detail)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().Float64Var(&minSimilarity, "min-similarity", 0.5, "Minimum similarity (0-1) of format strings for a changed statement to be reported as reworded instead of removed and added")
+	diffCmd.Flags().Bool("json", false, "Print results in json format")
+}
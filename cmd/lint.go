@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/kralicky/klog-inator/pkg/inator"
+	"github.com/spf13/cobra"
+)
+
+var lintChecks []string
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint pattern...",
+	Args:  cobra.MinimumNArgs(1),
+	Short: "Report misuse of logging functions",
+	Long: `Searches packages like the search command, and reports misuse of the
+logging functions: printf-style calls whose arguments do not match the format
+string, structured calls with an odd number of key/value arguments or
+non-constant keys, error logging with a nil error, formatting verbs in
+functions which are not printf-style, and calls to Fatal outside of package
+main.
+
+Exits with status 1 if anything was found.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		enabled := map[inator.LintCheck]bool{}
+		for _, c := range inator.LintChecks {
+			enabled[c] = len(lintChecks) == 0
+		}
+		for _, c := range lintChecks {
+			if _, ok := enabled[inator.LintCheck(c)]; !ok {
+				log.Fatalf("unknown check %q (available: %v)", c, inator.LintChecks)
+			}
+			enabled[inator.LintCheck(c)] = true
+		}
+
+		result, err := inator.Lint(args, searchOptions()...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		findings := []inator.LintFinding{}
+		for _, f := range result.Findings {
+			if enabled[f.Check] {
+				findings = append(findings, f)
+			}
+		}
+		result.Findings = findings
+
+		for _, d := range result.Diagnostics {
+			fmt.Fprintln(os.Stderr, d)
+		}
+		if printJson, _ := cmd.Flags().GetBool("json"); printJson {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(result); err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			for _, f := range findings {
+				fmt.Println(f)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "=> Found %d problems\n", len(findings))
+		if len(findings) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+	addSearchFlags(lintCmd)
+	lintCmd.Flags().StringSliceVar(&lintChecks, "checks", []string{}, "Checks to run (defaults to all)")
+	lintCmd.Flags().Bool("json", false, "Print results in json format")
+}
@@ -13,8 +13,8 @@ import (
 
 var searchList, logArchive, jsonField string
 var severityFilter, verbosityFilter []string
-var showAll, missed, fullPaths bool
-var top int
+var showAll, missed, fullPaths, showContext bool
+var top, maxLineDrift int
 
 func forEachVerbosityLevel(hit, missed map[int]int64, pct map[int]float64, fn func(string, int64, int64, float64)) {
 	for i := -1; i < 10; i++ {
@@ -40,13 +40,14 @@ var matchCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		sl, err := inator.LoadSearchList(searchList)
 		if err != nil {
-			panic(err)
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
 		fmt.Printf("Loaded search list with %d logs\n", len(sl))
 		fmt.Println("Computing log fingerprints...")
 		sm, collisions := sl.GenerateSearchMap()
 		fmt.Printf("=> Computed %d unique log fingerprints\n", len(sm))
-		fmt.Printf("=> %d collisions\n", len(collisions))
+		fmt.Printf("=> %d collisions (hits are attributed by the logged message)\n", len(collisions))
 		if len(collisions) > 0 {
 			for _, items := range collisions {
 				arrow := "==>"
@@ -60,6 +61,9 @@ var matchCmd = &cobra.Command{
 		if jsonField != "" {
 			options = append(options, inator.WithJSONField(jsonField))
 		}
+		if maxLineDrift > 0 {
+			options = append(options, inator.WithMaxLineDrift(maxLineDrift))
+		}
 		startTime := time.Now()
 		results, err := inator.Match(sm, logArchive, options...)
 		if err != nil {
@@ -73,6 +77,33 @@ var matchCmd = &cobra.Command{
 			int64(float64(results.NumMatched)/duration.Seconds()))
 		fmt.Printf("=> %d logs matched\n", results.NumMatched)
 		fmt.Printf("=> %d logs not matched\n", results.NumNotMatched)
+		if maxLineDrift > 0 {
+			fmt.Printf("=> %d logs attributed to a statement on a nearby line\n", results.NumDriftMatched)
+		}
+
+		if results.NumMismatched > 0 {
+			fmt.Printf("=> %d matched logs disagree with the format string of their statement (stale search list or fingerprint collision):\n", results.NumMismatched)
+			mismatched := inator.SortMatches(inator.AggregateResults(results.Mismatched))
+			if !showAll && len(mismatched) > top {
+				mismatched = mismatched[:top]
+			}
+			for _, entry := range mismatched {
+				fmt.Printf("   [%d hits] %s:%d: %s\n      e.g. %s\n",
+					len(entry.Hits), entry.Log.SourceFile, entry.Log.LineNumber, entry.Log.FormatString, entry.Hits[0].Message)
+			}
+		}
+
+		if results.NumAmbiguous > 0 {
+			fmt.Printf("=> %d matched logs match the format strings of several colliding statements:\n", results.NumAmbiguous)
+			for fp, logs := range results.Ambiguous {
+				arrow := "==>"
+				for _, item := range sm[fp] {
+					fmt.Printf("%s %s:%d: %s\n", arrow, item.SourceFile, item.LineNumber, item.FormatString)
+					arrow = "   "
+				}
+				fmt.Printf("    [%d hits] e.g. %s\n", len(logs), logs[0].Message)
+			}
+		}
 
 		fmt.Println("Aggregating results...")
 		aggregated := inator.AggregateResults(results.Matched)
@@ -176,6 +207,27 @@ func printEntries(entries []inator.MatchEntry) {
 			maxFilenameLen, formatFilename(entry.Log),
 			entry.Log.FormatString,
 		)
+		if showContext {
+			printContext(int(maxIndexLen), entry.Log)
+		}
+	}
+}
+
+// printContext prints the function containing the statement and the
+// arguments of the call, indented below the entry.
+func printContext(indent int, log *inator.LogStatement) {
+	var context []string
+	if fn := log.QualifiedFunction(); fn != "" {
+		context = append(context, "in "+fn)
+	}
+	if log.EndLineNumber > 0 {
+		context = append(context, fmt.Sprintf("call ends at %d:%d", log.EndLineNumber, log.EndColumn))
+	}
+	if len(log.Arguments) > 0 {
+		context = append(context, "args ("+strings.Join(log.Arguments, ", ")+")")
+	}
+	if len(context) > 0 {
+		fmt.Printf("%*s  %s\n", indent, "", strings.Join(context, ", "))
 	}
 }
 
@@ -184,10 +236,12 @@ func init() {
 	matchCmd.Flags().StringVarP(&searchList, "search-list", "s", "", "Search list to use (output of search --json)")
 	matchCmd.Flags().StringVarP(&logArchive, "log-archive", "l", "", "Log archive to search through")
 	matchCmd.Flags().StringVar(&jsonField, "json-field", "", "If the logs are in JSON format, read the log message from this field.")
+	matchCmd.Flags().IntVar(&maxLineDrift, "max-line-drift", 0, "If a log does not match a statement exactly, attribute it to a statement in the same file at most this many lines away whose format string matches the message")
 	matchCmd.Flags().BoolVar(&showAll, "all", false, "Show all matches instead of a limited number of top matches")
 	matchCmd.Flags().IntVar(&top, "top", 20, "Number of top matches to show (if --all is given, this is ignored)")
 	matchCmd.Flags().BoolVar(&missed, "missed", false, "Also show log messages with 0 matches")
 	matchCmd.Flags().BoolVar(&fullPaths, "full-paths", false, "Show full paths of source files")
+	matchCmd.Flags().BoolVar(&showContext, "context", false, "Show the function containing each statement and the arguments of the call")
 	matchCmd.Flags().StringSliceVar(&severityFilter, "severity", []string{}, "Only show log statements with these severity levels")
 	matchCmd.MarkFlagRequired("search-list")
 	matchCmd.MarkFlagRequired("log-archive")
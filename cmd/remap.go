@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/kralicky/klog-inator/pkg/inator"
+	"github.com/spf13/cobra"
+)
+
+var remapRepo, remapFrom, remapTo, remapSourceRoot string
+
+// remapCmd represents the remap command
+var remapCmd = &cobra.Command{
+	Use:   "remap",
+	Args:  cobra.NoArgs,
+	Short: "Rewrite the line numbers of a search list to another commit",
+	Long: `Rewrite the file names and line numbers of a search list generated at one
+commit to where the same lines are at another commit of a local git
+repository. Statements on lines which were deleted or modified in between
+are dropped. The remapped search list is printed in json format.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		file, err := inator.LoadSearchListFile(searchList)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts := []inator.RemapOption{
+			inator.WithFromRevision(remapFrom),
+		}
+		if remapSourceRoot != "" {
+			opts = append(opts, inator.WithSourceRoot(remapSourceRoot))
+		}
+		remapped, stats, err := inator.RemapSearchList(file.Statements, remapRepo, remapTo, opts...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "=> %d moved, %d unchanged, %d dropped, %d outside of the repository\n",
+			stats.NumMoved, stats.NumUnchanged, stats.NumDropped, stats.NumOutside)
+		remapped.Sort()
+		file.Statements = remapped
+		if err := file.WriteJSON(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(remapCmd)
+	remapCmd.Flags().StringVarP(&searchList, "search-list", "s", "", "Search list to remap (output of search --json)")
+	remapCmd.Flags().StringVar(&remapRepo, "repo", ".", "Path to the git repository")
+	remapCmd.Flags().StringVar(&remapFrom, "from", "", "Commit the search list was generated at (defaults to the revision recorded in the search list)")
+	remapCmd.Flags().StringVar(&remapTo, "to", "HEAD", "Commit to remap the search list to")
+	remapCmd.Flags().StringVar(&remapSourceRoot, "source-root", "", "Directory the search was run in (defaults to the current directory)")
+	remapCmd.MarkFlagRequired("search-list")
+}
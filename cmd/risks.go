@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/kralicky/klog-inator/pkg/inator"
+	"github.com/spf13/cobra"
+)
+
+var minRiskLevel string
+
+// risksCmd represents the risks command
+var risksCmd = &cobra.Command{
+	Use:   "risks",
+	Args:  cobra.NoArgs,
+	Short: "List log statements which may log sensitive data",
+	Long: `List the statements of a search list with arguments which may contain
+sensitive data: values named like tokens, passwords, secrets, keys or
+credentials, whole HTTP requests or Secret objects, and structs with such
+fields formatted with %+v. Statements with the highest risk are listed first.
+
+The type of arguments is only known for variables declared in the same file,
+unless the search was run with --type-check.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		min, err := inator.ParseRiskLevel(minRiskLevel)
+		if err != nil {
+			log.Fatal(err)
+		}
+		file, err := inator.LoadSearchListFile(searchList)
+		if err != nil {
+			log.Fatal(err)
+		}
+		total := len(file.Statements)
+		risky := file.Statements.Risky(min)
+		levels := map[inator.RiskLevel]int{inator.RiskHigh: 0, inator.RiskMedium: 1, inator.RiskLow: 2}
+		sort.SliceStable(risky, func(i, j int) bool {
+			return levels[risky[i].RiskLevel()] < levels[risky[j].RiskLevel()]
+		})
+
+		if printJson, _ := cmd.Flags().GetBool("json"); printJson {
+			file.Statements = risky
+			file.Diagnostics = nil
+			if err := file.WriteJSON(os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			for _, stmt := range risky {
+				fmt.Printf("%s:%d %s %s\n", stmt.SourceFile, stmt.LineNumber, stmt.RiskLevel(), stmt.FormatString)
+				for _, risk := range stmt.Risks {
+					fmt.Printf("\t%s\n", risk)
+				}
+			}
+		}
+		fmt.Fprintf(os.Stderr, "=> %d of %d statements may log sensitive data\n", len(risky), total)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(risksCmd)
+	risksCmd.Flags().StringVarP(&searchList, "search-list", "s", "", "Search list to check (output of search --json)")
+	risksCmd.Flags().StringVar(&minRiskLevel, "min-level", "low", "Only list statements with a risk of at least this level (low, medium or high)")
+	risksCmd.Flags().Bool("json", false, "Print the listed statements as a search list in json format")
+	risksCmd.MarkFlagRequired("search-list")
+}
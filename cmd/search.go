@@ -1,64 +1,58 @@
 package cmd
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
-	"strings"
 
 	"github.com/kralicky/klog-inator/pkg/inator"
 	"github.com/spf13/cobra"
 )
 
-var excludeModules, excludeFilenames, errorKeywords []string
+var excludeModules, excludeFilenames, errorKeywords, buildTags []string
+var goos, goarch, modFlag string
+var typeCheck, tests, strict, useCache bool
+var buildConfigs []string
+var registryFile, rulesFile, cacheDir string
 
 // searchCmd represents the search command
 var searchCmd = &cobra.Command{
-	Use:   "search [pattern]",
-	Args:  cobra.ExactArgs(1),
+	Use:   "search pattern...",
+	Args:  cobra.MinimumNArgs(1),
 	Short: "Search through packages for log statements",
 	Run: func(cmd *cobra.Command, args []string) {
-		list := exec.Command("go", "list", "-json", args[0])
-		output := new(bytes.Buffer)
-		list.Stdout = output
-		list.Stderr = os.Stderr
-		if err := list.Run(); err != nil {
-			log.Fatal(err)
-		}
-
-		// cant figure out how to do this in code lol
-		// the json decoder is way too slow
-		minified := new(bytes.Buffer)
-		jq := exec.Command("jq", "-c")
-		jq.Stdin = output
-		jq.Stdout = minified
-		jq.Stderr = os.Stderr
-		if err := jq.Run(); err != nil {
-			log.Fatal("jq error: " + err.Error())
+		opts := searchOptions()
+		var searchOpts []inator.SearchOption
+		if useCache {
+			cache, err := openCache()
+			if err != nil {
+				log.Fatal(err)
+			}
+			searchOpts = append(searchOpts, inator.WithCache(cache))
 		}
-		objects := strings.Split(minified.String(), "\n")
-		if objects[len(objects)-1] == "" {
-			objects = objects[:len(objects)-1]
+		result, err := inator.Search(args, append(searchOpts, opts...)...)
+		if err != nil {
+			log.Fatal(err)
 		}
-		statements := inator.Search(objects,
-			excludeModules,
-			append(excludeFilenames, "_test.go"),
-			errorKeywords,
-		)
 		printJson, _ := cmd.Flags().GetBool("json")
-		if printJson {
-			statementSlice := []*inator.LogStatement{}
-			for statement := range statements {
-				statementSlice = append(statementSlice, statement)
+		printNDJson, _ := cmd.Flags().GetBool("ndjson")
+		if printJson || printNDJson {
+			file := inator.SearchListFile{
+				Header:      inator.NewSearchListHeader(args, opts...),
+				Statements:  result.Statements,
+				Diagnostics: result.Diagnostics,
+			}
+			if printNDJson {
+				err = file.WriteNDJSON(os.Stdout)
+			} else {
+				err = file.WriteJSON(os.Stdout)
+			}
+			if err != nil {
+				log.Fatal(err)
 			}
-			data, _ := json.Marshal(statementSlice)
-			fmt.Println(string(data))
 		} else {
-			for statement := range statements {
-				var severity, verbosity string
+			for _, statement := range result.Statements {
+				var severity string
 				switch statement.Severity {
 				case 0:
 					severity = "INFO"
@@ -72,24 +66,106 @@ var searchCmd = &cobra.Command{
 					severity = "UNKNOWN"
 				}
 
-				if statement.Verbosity == nil {
-					verbosity = "N/A"
-				} else {
-					verbosity = fmt.Sprint(*statement.Verbosity)
+				var audit string
+				if statement.OriginalSeverity != nil {
+					audit = fmt.Sprintf(" (%s -> %s by rule %q)", statement.OriginalSeverity, statement.Severity, statement.SeverityRule)
 				}
-
-				fmt.Printf("%s:%d %s %s %s\n",
+				fmt.Printf("%s:%d %s %s %s%s\n",
 					statement.SourceFile, statement.LineNumber,
-					severity, verbosity, statement.FormatString)
+					severity, statement.VerbosityString(), statement.FormatString, audit)
+			}
+		}
+
+		counts := map[inator.DiagnosticKind]int{}
+		for _, d := range result.Diagnostics {
+			fmt.Fprintln(os.Stderr, d)
+			counts[d.Kind]++
+		}
+		fmt.Fprintf(os.Stderr, "=> Found %d log statements\n", len(result.Statements))
+		if useCache {
+			fmt.Fprintf(os.Stderr, "=> %d files cached, %d files searched\n", result.CacheHits, result.CacheMisses)
+		}
+		for _, kind := range []inator.DiagnosticKind{
+			inator.DiagnosticLoadError,
+			inator.DiagnosticPackageError,
+			inator.DiagnosticParseError,
+			inator.DiagnosticSearchError,
+			inator.DiagnosticPackageExcluded,
+			inator.DiagnosticFileExcluded,
+		} {
+			if counts[kind] > 0 {
+				fmt.Fprintf(os.Stderr, "=> %d %s\n", counts[kind], kind)
 			}
 		}
+		if strict && len(result.Diagnostics) > 0 {
+			fmt.Fprintf(os.Stderr, "%d diagnostics reported during the search (--strict)\n", len(result.Diagnostics))
+			os.Exit(1)
+		}
 	},
 }
 
+// searchOptions returns the options set by the flags shared by the commands
+// which search packages.
+func searchOptions() []inator.SearchOption {
+	configs := make([]inator.BuildConfig, 0, len(buildConfigs))
+	for _, c := range buildConfigs {
+		config, err := inator.ParseBuildConfig(c)
+		if err != nil {
+			log.Fatal(err)
+		}
+		configs = append(configs, config)
+	}
+	opts := []inator.SearchOption{
+		inator.WithBuildTags(buildTags...),
+		inator.WithGOOS(goos),
+		inator.WithGOARCH(goarch),
+		inator.WithModFlag(modFlag),
+		inator.WithExcludeModules(excludeModules...),
+		inator.WithExcludeFilenames(excludeFilenames...),
+		inator.WithErrorKeywords(errorKeywords...),
+		inator.WithTypeCheck(typeCheck),
+		inator.WithBuildConfigs(configs...),
+		inator.WithTests(tests),
+	}
+	if registryFile != "" {
+		reg, err := inator.LoadRegistry(registryFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, inator.WithRegistry(reg))
+	}
+	if rulesFile != "" {
+		rules, err := inator.LoadSeverityRules(rulesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, inator.WithSeverityRules(rules))
+	}
+	return opts
+}
+
+// addSearchFlags adds the flags used by searchOptions to the command.
+func addSearchFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&excludeModules, "exclude-modules", []string{}, "Modules to exclude (substrings)")
+	cmd.Flags().StringSliceVar(&excludeFilenames, "exclude-filenames", []string{}, "Filenames to exclude (substrings)")
+	cmd.Flags().StringSliceVar(&errorKeywords, "error-keywords", []string{}, "Treat log messages containing these keywords as errors, if they are logged as Info")
+	cmd.Flags().StringSliceVar(&buildTags, "tags", []string{}, "Build tags to use when loading packages")
+	cmd.Flags().StringVar(&goos, "goos", "", "Target operating system (defaults to $GOOS)")
+	cmd.Flags().StringVar(&goarch, "goarch", "", "Target architecture (defaults to $GOARCH)")
+	cmd.Flags().StringVar(&modFlag, "mod", "", "Module download mode to use when loading packages (readonly, vendor, or mod)")
+	cmd.Flags().StringArrayVar(&buildConfigs, "build-config", []string{}, "Search once for each build configuration (GOOS/GOARCH[:tag,...]) and merge the results. Can be repeated.")
+	cmd.Flags().BoolVar(&tests, "tests", false, "Include _test.go files")
+	cmd.Flags().BoolVar(&typeCheck, "type-check", false, "Use type information to find klog calls (slower, but finds calls made through variables and parameters and the callers of helper methods, and evaluates constants of other packages)")
+	cmd.Flags().StringVar(&rulesFile, "rules", "", "YAML or JSON file with rules reclassifying the severity of log statements")
+	cmd.Flags().StringVar(&registryFile, "registry", "", "YAML or JSON file declaring additional logging packages and functions")
+}
+
 func init() {
 	rootCmd.AddCommand(searchCmd)
-	searchCmd.Flags().StringSliceVar(&excludeModules, "exclude-modules", []string{}, "Modules to exclude (substrings)")
-	searchCmd.Flags().StringSliceVar(&excludeFilenames, "exclude-filenames", []string{}, "Filenames to exclude (substrings)")
-	searchCmd.Flags().StringSliceVar(&errorKeywords, "error-keywords", []string{}, "Treat log messages containing these keywords as errors, if they are logged as Info")
+	addSearchFlags(searchCmd)
+	searchCmd.Flags().BoolVar(&strict, "strict", false, "Exit with an error if the search reports any diagnostic, including excluded packages and files")
+	searchCmd.Flags().BoolVar(&useCache, "cache", false, "Reuse the results of previous searches for files which did not change (ignored with --type-check)")
+	searchCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Directory of the search cache (defaults to klog-inator in the user cache directory)")
 	searchCmd.Flags().Bool("json", false, "Print results in json format")
+	searchCmd.Flags().Bool("ndjson", false, "Print results in newline-delimited json format (one statement per line)")
 }
@@ -1,11 +1,13 @@
 module github.com/kralicky/klog-inator
 
-go 1.17
+go 1.22.0
 
 require (
 	github.com/spf13/cobra v1.2.1
+	github.com/valyala/fastjson v1.6.3
 	go.uber.org/atomic v1.7.0
-	golang.org/x/tools v0.1.7
+	golang.org/x/tools v0.30.0
+	gopkg.in/yaml.v2 v2.4.0
 	k8s.io/klog/v2 v2.30.0
 )
 
@@ -13,8 +15,8 @@ require (
 	github.com/go-logr/logr v1.2.0 // indirect
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	github.com/valyala/fastjson v1.6.3 // indirect
-	golang.org/x/mod v0.4.2 // indirect
-	golang.org/x/sys v0.0.0-20210823070655-63515b42dcdf // indirect
+	golang.org/x/mod v0.23.0 // indirect
+	golang.org/x/sync v0.11.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 )
@@ -0,0 +1,56 @@
+package inator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BuildConfig is a target platform and set of build tags to load packages
+// with. Empty fields use the defaults of the go command.
+type BuildConfig struct {
+	GOOS   string
+	GOARCH string
+	Tags   []string
+}
+
+// ParseBuildConfig parses a build configuration of the form
+// GOOS/GOARCH[:tag,tag...], e.g. "linux/amd64" or "windows/amd64:providerless".
+// Either part of the platform may be left empty, as in "/arm64" or
+// ":providerless". The string "default" denotes the default configuration.
+func ParseBuildConfig(s string) (BuildConfig, error) {
+	var config BuildConfig
+	if s == "default" {
+		return config, nil
+	}
+	platform, tags := s, ""
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		platform, tags = s[:i], s[i+1:]
+	}
+	if platform != "" {
+		parts := strings.Split(platform, "/")
+		if len(parts) != 2 {
+			return BuildConfig{}, fmt.Errorf("invalid build config %q: expected GOOS/GOARCH[:tags]", s)
+		}
+		config.GOOS, config.GOARCH = parts[0], parts[1]
+	}
+	if tags != "" {
+		config.Tags = strings.Split(tags, ",")
+	}
+	return config, nil
+}
+
+// String returns the build configuration in the format accepted by
+// ParseBuildConfig.
+func (c BuildConfig) String() string {
+	s := c.GOOS + "/" + c.GOARCH
+	if s == "/" {
+		s = ""
+	}
+	if len(c.Tags) > 0 {
+		s += ":" + strings.Join(c.Tags, ",")
+	}
+	if s == "" {
+		return "default"
+	}
+	return s
+}
@@ -0,0 +1,34 @@
+package inator_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kralicky/klog-inator/pkg/inator"
+)
+
+func TestParseBuildConfig(t *testing.T) {
+	cases := map[string]inator.BuildConfig{
+		"default":                      {},
+		"linux/amd64":                  {GOOS: "linux", GOARCH: "amd64"},
+		"windows/amd64:providerless":   {GOOS: "windows", GOARCH: "amd64", Tags: []string{"providerless"}},
+		"linux/arm64:providerless,foo": {GOOS: "linux", GOARCH: "arm64", Tags: []string{"providerless", "foo"}},
+		":providerless":                {Tags: []string{"providerless"}},
+	}
+	for s, expected := range cases {
+		config, err := inator.ParseBuildConfig(s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		if !reflect.DeepEqual(config, expected) {
+			t.Errorf("%s: expected %+v, got %+v", s, expected, config)
+		}
+		if config.String() != s {
+			t.Errorf("%s: String() returned %q", s, config.String())
+		}
+	}
+	if _, err := inator.ParseBuildConfig("linux"); err == nil {
+		t.Error("expected an error for a config without GOARCH")
+	}
+}
@@ -0,0 +1,420 @@
+package inator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/atomic"
+	"golang.org/x/tools/go/packages"
+)
+
+// cacheVersion is part of every cache key. It must be incremented whenever
+// the statements found in a file could change for the same registries.
+const cacheVersion = 7
+
+// Cache stores the results of searching individual files on disk. Entries are
+// keyed by the content of the file, the other files of its package and the
+// registries used, so they never go stale; a changed file simply gets a new
+// entry. Old entries can be removed with Clear.
+type Cache struct {
+	dir string
+}
+
+// CacheEntry describes an entry in the cache.
+type CacheEntry struct {
+	Key           string
+	File          string
+	Package       string
+	NumStatements int
+	Size          int64
+	// LastUsed is the last time the entry was written or read by a search.
+	LastUsed time.Time
+}
+
+// cacheEntry is the content of an entry file.
+type cacheEntry struct {
+	File       string          `json:"file"`
+	Package    string          `json:"package"`
+	Statements []*LogStatement `json:"statements,omitempty"`
+	Wrappers   []cachedWrapper `json:"wrappers,omitempty"`
+	// Callees are the keys of the functions called in the file, which are
+	// needed to find the call sites of wrappers without parsing the file.
+	Callees []string `json:"callees,omitempty"`
+}
+
+type cachedWrapper struct {
+	Key       string        `json:"key"`
+	Depth     int           `json:"depth"`
+	Statement *LogStatement `json:"statement"`
+}
+
+// DefaultCacheDir returns the klog-inator directory in the user's cache
+// directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "klog-inator"), nil
+}
+
+// OpenCache returns the cache stored in dir, creating the directory if it
+// does not exist.
+func OpenCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// Dir returns the directory the cache is stored in.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Entries returns the entries in the cache, ordered by file name.
+func (c *Cache) Entries() ([]CacheEntry, error) {
+	var entries []CacheEntry
+	err := c.walk(func(path string, info fs.FileInfo) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var e cacheEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			// Searches treat invalid entries as missing.
+			e.File = "(invalid)"
+		}
+		entries = append(entries, CacheEntry{
+			Key:           strings.TrimSuffix(filepath.Base(path), ".json"),
+			File:          e.File,
+			Package:       e.Package,
+			NumStatements: len(e.Statements) + len(e.Wrappers),
+			Size:          info.Size(),
+			LastUsed:      info.ModTime(),
+		})
+		return nil
+	})
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].File != entries[j].File {
+			return entries[i].File < entries[j].File
+		}
+		return entries[i].Key < entries[j].Key
+	})
+	return entries, err
+}
+
+// Clear removes the entries which were not used for longer than the given
+// duration, or all entries if it is 0, and returns the number of entries
+// removed.
+func (c *Cache) Clear(unusedFor time.Duration) (int, error) {
+	removed := 0
+	cutoff := time.Now().Add(-unusedFor)
+	err := c.walk(func(path string, info fs.FileInfo) error {
+		if unusedFor > 0 && info.ModTime().After(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// walk calls fn for every entry file in the cache.
+func (c *Cache) walk(fn func(path string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(path, info)
+	})
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// get returns the entry with the given key, or false if there is none or it
+// cannot be read.
+func (c *Cache) get(key string) (*cacheEntry, bool) {
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	e := &cacheEntry{}
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return e, true
+}
+
+// put stores the entry under the given key. Errors are ignored, since the
+// cache is only an optimization.
+func (c *Cache) put(key string, e *cacheEntry) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	// Write to a temporary file first, so that concurrent searches never
+	// read a partially written entry.
+	tmp, err := os.CreateTemp(filepath.Dir(path), "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// cacheSession uses a cache for a single search.
+type cacheSession struct {
+	cache *Cache
+	// hash of the options affecting the statements found in a file
+	optionsKey   []byte
+	hits, misses *atomic.Int64
+}
+
+func newCacheSession(cache *Cache, options *SearchOptions) *cacheSession {
+	data, _ := json.Marshal(struct {
+		Version    int
+		Registries []*Registry
+	}{
+		Version:    cacheVersion,
+		Registries: append([]*Registry{DefaultRegistry()}, options.registries...),
+	})
+	sum := sha256.Sum256(data)
+	return &cacheSession{
+		cache:      cache,
+		optionsKey: sum[:],
+		hits:       atomic.NewInt64(0),
+		misses:     atomic.NewInt64(0),
+	}
+}
+
+// key returns the cache key of a file. Constants declared in one file of a
+// package can be used in all others, so the key depends on the content of
+// every file in the package (pkgSum), and changing any of them searches the
+// whole package again.
+func (s *cacheSession) key(pkgPath string, pkgSum, content []byte) string {
+	h := sha256.New()
+	h.Write(s.optionsKey)
+	h.Write([]byte(pkgPath))
+	h.Write([]byte{0})
+	h.Write(pkgSum)
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// searchPackage searches the files of the package which are not excluded by
+// the search options, like parsePackage and searchFile combined. Only the
+// files which are not in the cache are parsed.
+func (s *cacheSession) searchPackage(pkg *packages.Package, options *SearchOptions, wd string, diags *diagnosticLog) ([]*searchedFile, []*LogStatement, []wrapper) {
+	origin := newProvenance(pkg)
+	contents := make(map[string][]byte, len(pkg.GoFiles))
+	pkgHash := sha256.New()
+	for _, filename := range pkg.GoFiles {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			diags.add(Diagnostic{
+				Kind:    DiagnosticParseError,
+				Package: pkg.PkgPath,
+				File:    filename,
+				Message: err.Error(),
+			})
+			continue
+		}
+		contents[filename] = content
+		sum := sha256.Sum256(content)
+		pkgHash.Write(sum[:])
+	}
+	pkgSum := pkgHash.Sum(nil)
+
+	// The constants of the package are only parsed if a file has to be.
+	var consts packageConsts
+	loadConsts := func() packageConsts {
+		if consts == nil {
+			consts = parseConsts(contents)
+		}
+		return consts
+	}
+
+	var files []*searchedFile
+	var statements []*LogStatement
+	var wrappers []wrapper
+	for _, filename := range pkg.GoFiles {
+		content, ok := contents[filename]
+		if !ok || excludeFile(pkg, filename, options, diags) {
+			continue
+		}
+		relPath := relativePath(wd, filename)
+		func() {
+			defer diags.recover(pkg.PkgPath, relPath)
+			sf, stmts, ws := s.searchFile(pkg, filename, relPath, content, pkgSum, origin, loadConsts, options, diags)
+			if sf != nil {
+				files = append(files, sf)
+				statements = append(statements, stmts...)
+				wrappers = append(wrappers, ws...)
+			}
+		}()
+	}
+	return files, statements, wrappers
+}
+
+// parseConsts returns the constants declared in the files of a package.
+// Files which cannot be parsed are skipped.
+func parseConsts(contents map[string][]byte) packageConsts {
+	fset := token.NewFileSet()
+	files := make([]*ast.File, 0, len(contents))
+	for filename, content := range contents {
+		f, err := parser.ParseFile(fset, filename, content, 0)
+		if err != nil {
+			continue
+		}
+		files = append(files, f)
+	}
+	return collectConsts(files)
+}
+
+func (s *cacheSession) searchFile(pkg *packages.Package, filename, relPath string, content, pkgSum []byte, origin provenance, consts func() packageConsts, options *SearchOptions, diags *diagnosticLog) (*searchedFile, []*LogStatement, []wrapper) {
+	key := s.key(pkg.PkgPath, pkgSum, content)
+	if e, ok := s.cache.get(key); ok {
+		s.hits.Inc()
+		return s.restore(e, pkg, filename, relPath, origin, consts, options)
+	}
+	s.misses.Inc()
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, content, parser.ParseComments)
+	if err != nil {
+		diags.add(Diagnostic{
+			Kind:    DiagnosticParseError,
+			Package: pkg.PkgPath,
+			File:    filename,
+			Message: err.Error(),
+		})
+		return nil, nil, nil
+	}
+	sf := newSearchedFile(pkg, fset, f, relPath, origin, consts(), options)
+	stmts, ws := searchFile(sf, options)
+
+	e := &cacheEntry{
+		File:    filename,
+		Package: pkg.PkgPath,
+		Callees: fileCallees(sf),
+	}
+	// The location and provenance are filled in when the entry is restored,
+	// since they can change without the content of the file changing.
+	strip := func(stmt *LogStatement) *LogStatement {
+		copied := *stmt
+		copied.SourceFile = ""
+		provenance{}.apply(&copied)
+		return &copied
+	}
+	for _, stmt := range stmts {
+		e.Statements = append(e.Statements, strip(stmt))
+	}
+	for _, w := range ws {
+		e.Wrappers = append(e.Wrappers, cachedWrapper{Key: w.key, Depth: w.depth, Statement: strip(w.stmt)})
+	}
+	s.cache.put(key, e)
+	return sf, stmts, ws
+}
+
+// restore returns the statements and wrappers of a cache entry, and an
+// unparsed file which can be searched for calls to wrappers.
+func (s *cacheSession) restore(e *cacheEntry, pkg *packages.Package, filename, relPath string, origin provenance, consts func() packageConsts, options *SearchOptions) (*searchedFile, []*LogStatement, []wrapper) {
+	sf := &searchedFile{
+		pkg:     pkg,
+		relPath: relPath,
+		origin:  origin,
+		callees: make(map[string]bool, len(e.Callees)),
+	}
+	for _, callee := range e.Callees {
+		sf.callees[callee] = true
+	}
+	sf.load = func() bool {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+		if err != nil {
+			return false
+		}
+		parsed := newSearchedFile(pkg, fset, f, relPath, origin, consts(), options)
+		sf.ast, sf.fset, sf.resolver = parsed.ast, parsed.fset, parsed.resolver
+		return true
+	}
+	for _, stmt := range e.Statements {
+		stmt.SourceFile = relPath
+		origin.apply(stmt)
+	}
+	wrappers := make([]wrapper, len(e.Wrappers))
+	for i, w := range e.Wrappers {
+		w.Statement.SourceFile = relPath
+		origin.apply(w.Statement)
+		wrappers[i] = wrapper{key: w.Key, depth: w.Depth, stmt: w.Statement}
+	}
+	return sf, e.Statements, wrappers
+}
+
+// loadIfCalling parses a file restored from the cache if it calls any of the
+// wrappers, and reports whether it can be searched for the calls.
+func (sf *searchedFile) loadIfCalling(wrappers map[string][]wrapper) bool {
+	for key := range wrappers {
+		if sf.callees[key] {
+			return sf.load()
+		}
+	}
+	return false
+}
+
+// fileCallees returns the sorted keys of the functions called in the file.
+func fileCallees(sf *searchedFile) []string {
+	seen := map[string]bool{}
+	forEachDecl(sf.ast, func(node ast.Node, _, _ string, _ bool) {
+		inspectCalls(node, func(call *ast.CallExpr, _ bool) bool {
+			if key := sf.resolver.calleeKey(call); key != "" {
+				seen[key] = true
+			}
+			return true
+		})
+	})
+	callees := make([]string, 0, len(seen))
+	for key := range seen {
+		callees = append(callees, key)
+	}
+	sort.Strings(callees)
+	return callees
+}
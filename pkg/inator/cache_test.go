@@ -0,0 +1,56 @@
+package inator
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	cache, err := OpenCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := newCacheSession(cache, &SearchOptions{})
+	key := session.key("example.com/a", []byte("sum"), []byte("package a"))
+	if other := session.key("example.com/b", []byte("sum"), []byte("package a")); other == key {
+		t.Fatal("keys of files in different packages must differ")
+	}
+	if other := session.key("example.com/a", []byte("other sum"), []byte("package a")); other == key {
+		t.Fatal("keys of files in changed packages must differ")
+	}
+	if _, ok := cache.get(key); ok {
+		t.Fatal("unexpected entry in empty cache")
+	}
+
+	cache.put(key, &cacheEntry{
+		File:       "/src/a/a.go",
+		Package:    "example.com/a",
+		Statements: []*LogStatement{{LineNumber: 3, FormatString: `"hello"`}},
+		Callees:    []string{"example.com/a.helper"},
+	})
+	e, ok := cache.get(key)
+	if !ok {
+		t.Fatal("entry not found")
+	}
+	if len(e.Statements) != 1 || e.Statements[0].LineNumber != 3 || len(e.Callees) != 1 {
+		t.Errorf("unexpected entry %+v", e)
+	}
+
+	entries, err := cache.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Key != key || entries[0].File != "/src/a/a.go" || entries[0].NumStatements != 1 {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+
+	if removed, err := cache.Clear(time.Hour); err != nil || removed != 0 {
+		t.Errorf("Clear(1h) = %d, %v, want 0 entries removed", removed, err)
+	}
+	if removed, err := cache.Clear(0); err != nil || removed != 1 {
+		t.Errorf("Clear(0) = %d, %v, want 1 entry removed", removed, err)
+	}
+	if _, ok := cache.get(key); ok {
+		t.Error("entry found after clearing the cache")
+	}
+}
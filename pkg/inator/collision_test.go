@@ -0,0 +1,131 @@
+package inator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAttributeCollisions(t *testing.T) {
+	list := SearchList{
+		{SourceFile: "cmd/apiserver/app/options/options.go", LineNumber: 10, FormatString: `"Starting %s"`, MessageStyle: MessagePrintf},
+		{SourceFile: "cmd/scheduler/app/options/options.go", LineNumber: 10, FormatString: `"Loading config"`, MessageStyle: MessagePrint},
+		{SourceFile: "cmd/proxy/app/options/options.go", LineNumber: 10, FormatString: `"Loading config"`, MessageStyle: MessagePrint},
+	}
+	sm, collisions := list.GenerateSearchMap()
+	if len(sm) != 1 || len(collisions) != 1 {
+		t.Fatalf("expected a single fingerprint with 3 statements, got %d fingerprints and %d collisions", len(sm), len(collisions))
+	}
+	messages := compileMessageMatchers(sm)
+
+	cases := []struct {
+		log      ParsedLog
+		expected *LogStatement
+		tie      bool
+	}{
+		{ParsedLog{SourceFile: "options/options.go", LineNumber: 10, Message: "Starting apiserver"}, list[0], false},
+		// Both statements have the same format string.
+		{ParsedLog{SourceFile: "options/options.go", LineNumber: 10, Message: "Loading config"}, nil, true},
+		// A longer path tells them apart.
+		{ParsedLog{SourceFile: "proxy/app/options/options.go", LineNumber: 10, Message: "Loading config"}, list[2], false},
+		{ParsedLog{SourceFile: "options/options.go", LineNumber: 10, Message: "unrelated"}, nil, false},
+	}
+	for _, c := range cases {
+		if stmt, tie := attribute(sm.Lookup(c.log), c.log, messages); stmt != c.expected || tie != c.tie {
+			t.Errorf("%s %q: got %+v (tie %v), want %+v (tie %v)", c.log.SourceFile, c.log.Message, stmt, tie, c.expected, c.tie)
+		}
+	}
+}
+
+func TestMatcherCollisions(t *testing.T) {
+	list := SearchList{
+		{SourceFile: "cmd/apiserver/app/options/options.go", LineNumber: 10, FormatString: `"Starting %s"`, MessageStyle: MessagePrintf},
+		{SourceFile: "cmd/scheduler/app/options/options.go", LineNumber: 10, FormatString: `"Loading config %s"`, MessageStyle: MessagePrintf},
+		{SourceFile: "cmd/proxy/app/options/options.go", LineNumber: 10, FormatString: `"Loading %s"`, MessageStyle: MessagePrintf},
+	}
+	sm, _ := list.GenerateSearchMap()
+	// lines as read from an archive, without a line ending
+	lines := []string{
+		"I1105 13:30:39.614388  739568 options/options.go:10] Starting apiserver",
+		"I1105 13:30:39.614388  739568 options/options.go:10] Loading config from disk",
+		"I1105 13:30:39.614388  739568 options/options.go:10] Loading plugins",
+		"I1105 13:30:39.614388  739568 options/options.go:10] unrelated",
+	}
+	parsed := make(chan ParsedLog, len(lines))
+	for _, line := range lines {
+		p, ok := ParseLine([]byte(line))
+		if !ok {
+			t.Fatalf("%q: not parsed", line)
+		}
+		parsed <- p
+	}
+	close(parsed)
+	counters := &matchCounters{}
+	hit, mismatched, ambiguous := matcher(sm, nil, compileMessageMatchers(sm), counters, parsed)
+
+	if len(hit) != 2 || hit[list[0]] == nil || hit[list[2]] == nil {
+		t.Errorf("expected hits for the apiserver and proxy statements, got %v", hit)
+	}
+	if len(mismatched) != 0 {
+		t.Errorf("expected no mismatched logs, got %v", mismatched)
+	}
+	if len(ambiguous) != 1 {
+		t.Errorf("expected one ambiguous fingerprint, got %v", ambiguous)
+	}
+	if counters.matched.Load() != 3 || counters.notMatched.Load() != 1 || counters.ambiguous.Load() != 1 {
+		t.Errorf("got %d matched, %d not matched, %d ambiguous, want 3, 1, 1",
+			counters.matched.Load(), counters.notMatched.Load(), counters.ambiguous.Load())
+	}
+}
+
+func TestSearchMapLookup(t *testing.T) {
+	a := &LogStatement{SourceFile: "pkg/a/util/util.go", LineNumber: 5, Package: "k8s.io/kubernetes/pkg/a/util"}
+	b := &LogStatement{SourceFile: "pkg/b/util/util.go", LineNumber: 5, Package: "k8s.io/kubernetes/pkg/b/util"}
+	dep := &LogStatement{SourceFile: "../go/pkg/mod/k8s.io/klog/v2@v2.30.0/util/util.go", LineNumber: 5, Package: "k8s.io/klog/v2/util"}
+	sm, _ := SearchList{a, b, dep}.GenerateSearchMap()
+
+	cases := map[string][]*LogStatement{
+		"util/util.go":   {a, b, dep},
+		"b/util/util.go": {b},
+		"/home/user/kubernetes/pkg/a/util/util.go":             {a},
+		"k8s.io/kubernetes/pkg/b/util/util.go":                 {b},
+		"k8s.io/klog/v2@v2.30.0/util/util.go":                  {dep},
+		"/root/go/pkg/mod/k8s.io/klog/v2@v2.30.0/util/util.go": {dep},
+		"c/util/util.go": nil,
+	}
+	for path, expected := range cases {
+		if got := sm.Lookup(ParsedLog{SourceFile: path, LineNumber: 5}); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: got %d statements %v, want %v", path, len(got), got, expected)
+		}
+	}
+}
+
+func TestMatcherCounters(t *testing.T) {
+	list := SearchList{
+		{SourceFile: "a/options/options.go", LineNumber: 10, FormatString: `"Loading config"`, MessageStyle: MessagePrint},
+		{SourceFile: "b/options/options.go", LineNumber: 10, FormatString: `"Loading config"`, MessageStyle: MessagePrint},
+		{SourceFile: "c/c.go", LineNumber: 5, FormatString: `"Starting"`, MessageStyle: MessagePrint},
+	}
+	sm, _ := list.GenerateSearchMap()
+	messages := compileMessageMatchers(sm)
+	logs := []ParsedLog{
+		{SourceFile: "options/options.go", LineNumber: 10, Message: "Loading config"},
+		{SourceFile: "c/c.go", LineNumber: 5, Message: "Starting"},
+		{SourceFile: "c/c.go", LineNumber: 5, Message: "Stopping"},
+		{SourceFile: "d/d.go", LineNumber: 1, Message: "unknown"},
+	}
+	// counters must not carry over from one call to Match to the next
+	for i := 0; i < 2; i++ {
+		parsed := make(chan ParsedLog, len(logs))
+		for _, p := range logs {
+			parsed <- p
+		}
+		close(parsed)
+		counters := &matchCounters{}
+		matcher(sm, nil, messages, counters, parsed)
+		if counters.matched.Load() != 3 || counters.notMatched.Load() != 1 ||
+			counters.ambiguous.Load() != 1 || counters.mismatched.Load() != 1 {
+			t.Errorf("run %d: got %d matched, %d not matched, %d ambiguous, %d mismatched, want 3, 1, 1, 1", i+1,
+				counters.matched.Load(), counters.notMatched.Load(), counters.ambiguous.Load(), counters.mismatched.Load())
+		}
+	}
+}
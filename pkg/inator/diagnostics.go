@@ -0,0 +1,112 @@
+package inator
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DiagnosticKind classifies the diagnostics reported by Search.
+type DiagnosticKind string
+
+const (
+	// A build configuration could not be loaded at all.
+	DiagnosticLoadError DiagnosticKind = "load-error"
+	// The go command or type checker reported an error for a package. The
+	// package is still searched, but statements may be missing.
+	DiagnosticPackageError DiagnosticKind = "package-error"
+	// A file could not be parsed and was skipped.
+	DiagnosticParseError DiagnosticKind = "parse-error"
+	// Searching a file failed unexpectedly, and it was skipped.
+	DiagnosticSearchError DiagnosticKind = "search-error"
+	// A package or file was excluded by the search options.
+	DiagnosticPackageExcluded DiagnosticKind = "package-excluded"
+	DiagnosticFileExcluded    DiagnosticKind = "file-excluded"
+)
+
+// Diagnostic describes a problem encountered during a search, or a package
+// or file left out of it.
+type Diagnostic struct {
+	Kind    DiagnosticKind `json:"kind"`
+	Package string         `json:"package,omitempty"`
+	File    string         `json:"file,omitempty"`
+	Message string         `json:"message,omitempty"`
+	// BuildConfig is set if the search was run over several build
+	// configurations.
+	BuildConfig string `json:"buildConfig,omitempty"`
+}
+
+// IsError reports whether the diagnostic means that statements may be
+// missing from the search results. Packages and files excluded by the search
+// options are not errors.
+func (d Diagnostic) IsError() bool {
+	switch d.Kind {
+	case DiagnosticPackageExcluded, DiagnosticFileExcluded:
+		return false
+	}
+	return true
+}
+
+func (d Diagnostic) String() string {
+	s := string(d.Kind)
+	if d.BuildConfig != "" {
+		s += " [" + d.BuildConfig + "]"
+	}
+	switch {
+	case d.File != "":
+		s += ": " + d.File
+	case d.Package != "":
+		s += ": " + d.Package
+	}
+	if d.Message != "" {
+		s += ": " + d.Message
+	}
+	return s
+}
+
+// SearchResult contains the statements found by Search, and the diagnostics
+// collected along the way.
+type SearchResult struct {
+	Statements  SearchList
+	Diagnostics []Diagnostic
+	// CacheHits and CacheMisses are the number of files whose results were
+	// restored from the cache or had to be searched, if a cache was used.
+	CacheHits, CacheMisses int
+}
+
+// Errors returns the diagnostics which are errors.
+func (r *SearchResult) Errors() []Diagnostic {
+	var errs []Diagnostic
+	for _, d := range r.Diagnostics {
+		if d.IsError() {
+			errs = append(errs, d)
+		}
+	}
+	return errs
+}
+
+// diagnosticLog collects diagnostics from concurrently searched packages.
+type diagnosticLog struct {
+	mu          sync.Mutex
+	buildConfig string
+	list        []Diagnostic
+}
+
+func (l *diagnosticLog) add(d Diagnostic) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	d.BuildConfig = l.buildConfig
+	l.list = append(l.list, d)
+}
+
+// recover records a panic while searching a file as a diagnostic. It must be
+// called directly by a deferred function call.
+func (l *diagnosticLog) recover(pkgPath, file string) {
+	if r := recover(); r != nil {
+		l.add(Diagnostic{
+			Kind:    DiagnosticSearchError,
+			Package: pkgPath,
+			File:    file,
+			Message: fmt.Sprint(r),
+		})
+	}
+}
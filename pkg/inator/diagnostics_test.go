@@ -0,0 +1,40 @@
+package inator_test
+
+import (
+	"testing"
+
+	"github.com/kralicky/klog-inator/pkg/inator"
+)
+
+func TestSearchDiagnostics(t *testing.T) {
+	cases := map[string]struct {
+		opts []inator.SearchOption
+		// the diagnostic expected for the file or package, and the number of
+		// statements still found
+		kind       inator.DiagnosticKind
+		statements int
+	}{
+		"./broken/imports": {opts: []inator.SearchOption{inator.WithTypeCheck(true)}, kind: inator.DiagnosticPackageError, statements: 1},
+		"./broken/syntax":  {kind: inator.DiagnosticParseError, statements: 1},
+		"./broken/types":   {opts: []inator.SearchOption{inator.WithTypeCheck(true)}, kind: inator.DiagnosticPackageError, statements: 1},
+		"./server":         {opts: []inator.SearchOption{inator.WithExcludeFilenames("server.go")}, kind: inator.DiagnosticFileExcluded},
+		"./helper":         {opts: []inator.SearchOption{inator.WithExcludeModules("example.com/example/helper")}, kind: inator.DiagnosticPackageExcluded},
+	}
+	for pattern, c := range cases {
+		result := searchTestdata(t, "example", []string{pattern}, c.opts...)
+		found := false
+		for _, d := range result.Diagnostics {
+			found = found || d.Kind == c.kind
+		}
+		if !found {
+			t.Errorf("%s: expected a %s diagnostic, got %v", pattern, c.kind, result.Diagnostics)
+		}
+		if len(result.Statements) != c.statements {
+			t.Errorf("%s: expected %d statements, got %d", pattern, c.statements, len(result.Statements))
+		}
+		excluded := c.kind == inator.DiagnosticFileExcluded || c.kind == inator.DiagnosticPackageExcluded
+		if errs := result.Errors(); excluded != (len(errs) == 0) {
+			t.Errorf("%s: got errors %v", pattern, errs)
+		}
+	}
+}
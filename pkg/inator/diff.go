@@ -0,0 +1,352 @@
+package inator
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// ChangeKind is a way in which a statement changed between two search lists.
+type ChangeKind string
+
+const (
+	ChangeAdded   ChangeKind = "added"
+	ChangeRemoved ChangeKind = "removed"
+	// The statement moved to another file or function. Line shifts within
+	// the same function are not reported.
+	ChangeMoved     ChangeKind = "moved"
+	ChangeSeverity  ChangeKind = "severity"
+	ChangeVerbosity ChangeKind = "verbosity"
+	ChangeReworded  ChangeKind = "reworded"
+)
+
+// StatementChange describes a statement which differs between two search
+// lists. Old is nil for added statements and New is nil for removed ones.
+type StatementChange struct {
+	Kinds []ChangeKind  `json:"kinds"`
+	Old   *LogStatement `json:"old,omitempty"`
+	New   *LogStatement `json:"new,omitempty"`
+	// Similarity of the old and new format strings, between 0 and 1.
+	Similarity float64 `json:"similarity,omitempty"`
+}
+
+// Has reports whether the change is of the given kind.
+func (c StatementChange) Has(kind ChangeKind) bool {
+	for _, k := range c.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchListDiff is the result of DiffSearchLists.
+type SearchListDiff struct {
+	Changes      []StatementChange `json:"changes"`
+	NumUnchanged int               `json:"numUnchanged"`
+}
+
+// Count returns the number of changes of the given kind.
+func (d *SearchListDiff) Count(kind ChangeKind) int {
+	n := 0
+	for _, c := range d.Changes {
+		if c.Has(kind) {
+			n++
+		}
+	}
+	return n
+}
+
+type DiffOptions struct {
+	minSimilarity float64
+}
+
+type DiffOption func(*DiffOptions)
+
+func (o *DiffOptions) Apply(opts ...DiffOption) {
+	for _, op := range opts {
+		op(o)
+	}
+}
+
+// WithMinSimilarity sets how similar (between 0 and 1) the format strings of
+// two statements in the same function must be for a changed format string to
+// be reported as reworded, rather than as a removed and an added statement.
+// The default is 0.5.
+func WithMinSimilarity(similarity float64) DiffOption {
+	return func(o *DiffOptions) {
+		o.minSimilarity = similarity
+	}
+}
+
+// DiffSearchLists compares two search lists, such as the lists of two
+// releases. Statements are paired in several passes, each only considering
+// the statements left unpaired by the previous ones:
+//  1. Same file, line and format string
+//  2. Same file, function and format string (the nearest line is preferred)
+//  3. Same package, function and format string in another file
+//  4. Same file and function, with the most similar format string
+//
+// Paired statements are reported if they moved to another file or function,
+// or their severity, verbosity or format string changed. The remaining
+// statements are reported as added or removed.
+func DiffSearchLists(old, new SearchList, opts ...DiffOption) *SearchListDiff {
+	options := DiffOptions{
+		minSimilarity: 0.5,
+	}
+	options.Apply(opts...)
+
+	d := &differ{
+		old:     old,
+		new:     new,
+		oldPair: make([]int, len(old)),
+		newPair: make([]int, len(new)),
+	}
+	for i := range d.oldPair {
+		d.oldPair[i] = -1
+	}
+	for i := range d.newPair {
+		d.newPair[i] = -1
+	}
+	d.pairBy(func(s *LogStatement) string {
+		return fileKey(s) + ":" + strconv.Itoa(s.LineNumber) + ":" + s.FormatString
+	})
+	d.pairBy(func(s *LogStatement) string {
+		return fileKey(s) + ":" + s.Function + ":" + s.FormatString
+	})
+	d.pairBy(func(s *LogStatement) string {
+		if s.Package == "" || s.Function == "" {
+			return ""
+		}
+		return s.Package + ":" + s.Function + ":" + s.FormatString
+	})
+	similarity := d.pairSimilar(options.minSimilarity)
+
+	diff := &SearchListDiff{}
+	for i, stmt := range old {
+		j := d.oldPair[i]
+		if j < 0 {
+			diff.Changes = append(diff.Changes, StatementChange{
+				Kinds: []ChangeKind{ChangeRemoved},
+				Old:   stmt,
+			})
+			continue
+		}
+		kinds := compareStatements(stmt, new[j])
+		if len(kinds) == 0 {
+			diff.NumUnchanged++
+			continue
+		}
+		diff.Changes = append(diff.Changes, StatementChange{
+			Kinds:      kinds,
+			Old:        stmt,
+			New:        new[j],
+			Similarity: similarity[i],
+		})
+	}
+	for j, stmt := range new {
+		if d.newPair[j] < 0 {
+			diff.Changes = append(diff.Changes, StatementChange{
+				Kinds: []ChangeKind{ChangeAdded},
+				New:   stmt,
+			})
+		}
+	}
+	sort.SliceStable(diff.Changes, func(i, j int) bool {
+		a, b := diff.Changes[i].current(), diff.Changes[j].current()
+		if a.SourceFile != b.SourceFile {
+			return a.SourceFile < b.SourceFile
+		}
+		return a.LineNumber < b.LineNumber
+	})
+	return diff
+}
+
+// current returns the new statement, or the old one if it was removed.
+func (c StatementChange) current() *LogStatement {
+	if c.New != nil {
+		return c.New
+	}
+	return c.Old
+}
+
+// fileKey identifies the file containing a statement independently of where
+// the module was located on disk, e.g. in a versioned module cache
+// directory.
+func fileKey(s *LogStatement) string {
+	if s.Package != "" {
+		return s.Package + "/" + filepath.Base(s.SourceFile)
+	}
+	return s.SourceFile
+}
+
+type differ struct {
+	old, new SearchList
+	// index of the paired statement in the other list, or -1
+	oldPair, newPair []int
+}
+
+// pairBy pairs unpaired statements with equal, non-empty keys. If several old
+// statements have the same key, the one closest to the new statement is
+// chosen.
+func (d *differ) pairBy(key func(*LogStatement) string) {
+	candidates := map[string][]int{}
+	for i, stmt := range d.old {
+		if d.oldPair[i] >= 0 {
+			continue
+		}
+		if k := key(stmt); k != "" {
+			candidates[k] = append(candidates[k], i)
+		}
+	}
+	for j, stmt := range d.new {
+		if d.newPair[j] >= 0 {
+			continue
+		}
+		k := key(stmt)
+		if k == "" {
+			continue
+		}
+		best := -1
+		for _, i := range candidates[k] {
+			if d.oldPair[i] >= 0 {
+				continue
+			}
+			if best < 0 || distance(d.old[i], stmt) < distance(d.old[best], stmt) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			d.oldPair[best] = j
+			d.newPair[j] = best
+		}
+	}
+}
+
+// pairSimilar pairs unpaired statements in the same file and function whose
+// format strings are at least minSimilarity similar, most similar first. It
+// returns the similarity of each paired old statement.
+func (d *differ) pairSimilar(minSimilarity float64) map[int]float64 {
+	type candidate struct {
+		i, j       int
+		similarity float64
+	}
+	groups := map[string][]int{}
+	for i, stmt := range d.old {
+		if d.oldPair[i] < 0 {
+			k := fileKey(stmt) + ":" + stmt.Function
+			groups[k] = append(groups[k], i)
+		}
+	}
+	var candidates []candidate
+	for j, stmt := range d.new {
+		if d.newPair[j] >= 0 {
+			continue
+		}
+		for _, i := range groups[fileKey(stmt)+":"+stmt.Function] {
+			if s := similarity(d.old[i].FormatString, stmt.FormatString); s >= minSimilarity {
+				candidates = append(candidates, candidate{i, j, s})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		if candidates[a].similarity != candidates[b].similarity {
+			return candidates[a].similarity > candidates[b].similarity
+		}
+		return distance(d.old[candidates[a].i], d.new[candidates[a].j]) <
+			distance(d.old[candidates[b].i], d.new[candidates[b].j])
+	})
+	result := map[int]float64{}
+	for _, c := range candidates {
+		if d.oldPair[c.i] >= 0 || d.newPair[c.j] >= 0 {
+			continue
+		}
+		d.oldPair[c.i] = c.j
+		d.newPair[c.j] = c.i
+		result[c.i] = c.similarity
+	}
+	return result
+}
+
+func distance(a, b *LogStatement) int {
+	d := a.LineNumber - b.LineNumber
+	if d < 0 {
+		d = -d
+	}
+	if fileKey(a) != fileKey(b) {
+		d += 1 << 20
+	}
+	return d
+}
+
+func compareStatements(old, new *LogStatement) []ChangeKind {
+	var kinds []ChangeKind
+	if fileKey(old) != fileKey(new) || old.Function != new.Function {
+		kinds = append(kinds, ChangeMoved)
+	}
+	if old.Severity != new.Severity {
+		kinds = append(kinds, ChangeSeverity)
+	}
+	if old.VerbosityString() != new.VerbosityString() {
+		kinds = append(kinds, ChangeVerbosity)
+	}
+	if old.FormatString != new.FormatString {
+		kinds = append(kinds, ChangeReworded)
+	}
+	return kinds
+}
+
+// VerbosityString formats the V-level of the statement: the level if it is
+// known, ?(expr) if the statement is V-gated with an unknown level, and N/A
+// if it is not V-gated.
+func (s LogStatement) VerbosityString() string {
+	switch {
+	case s.VerbosityUnknown:
+		return "?(" + s.VerbosityExpr + ")"
+	case s.Verbosity == nil:
+		return "N/A"
+	default:
+		return fmt.Sprint(*s.Verbosity)
+	}
+}
+
+// similarity returns 1 minus the edit distance between a and b, relative to
+// the length of the longer string.
+func similarity(a, b string) float64 {
+	if unquoted, err := strconv.Unquote(a); err == nil {
+		a = unquoted
+	}
+	if unquoted, err := strconv.Unquote(b); err == nil {
+		b = unquoted
+	}
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,63 @@
+package inator_test
+
+import (
+	"testing"
+
+	"github.com/kralicky/klog-inator/pkg/inator"
+)
+
+func TestDiffSearchLists(t *testing.T) {
+	v2, v4 := 2, 4
+	stmt := func(file string, line int, fn string, severity inator.Severity, verbosity *int, format string) *inator.LogStatement {
+		return &inator.LogStatement{
+			SourceFile:   file,
+			LineNumber:   line,
+			Function:     fn,
+			Severity:     severity,
+			Verbosity:    verbosity,
+			FormatString: format,
+			Package:      "example.com/a",
+		}
+	}
+	old := inator.SearchList{
+		stmt("a/a.go", 10, "F", inator.SeverityInfo, &v2, `"unchanged"`),
+		stmt("a/a.go", 20, "F", inator.SeverityInfo, nil, `"shifted"`),
+		stmt("a/a.go", 30, "G", inator.SeverityInfo, nil, `"now an error"`),
+		stmt("a/a.go", 40, "G", inator.SeverityInfo, &v2, `"more verbose"`),
+		stmt("a/a.go", 50, "H", inator.SeverityWarning, nil, `"failed to sync pod %s"`),
+		stmt("a/a.go", 60, "H", inator.SeverityInfo, nil, `"gone"`),
+		stmt("a/b.go", 10, "K", inator.SeverityInfo, nil, `"moved to another file"`),
+	}
+	new := inator.SearchList{
+		stmt("a/a.go", 10, "F", inator.SeverityInfo, &v2, `"unchanged"`),
+		stmt("a/a.go", 25, "F", inator.SeverityInfo, nil, `"shifted"`),
+		stmt("a/a.go", 35, "G", inator.SeverityError, nil, `"now an error"`),
+		stmt("a/a.go", 45, "G", inator.SeverityInfo, &v4, `"more verbose"`),
+		stmt("a/a.go", 55, "H", inator.SeverityWarning, nil, `"failed to sync pod %q"`),
+		stmt("a/a.go", 70, "H", inator.SeverityInfo, nil, `"completely different text"`),
+		stmt("a/c.go", 10, "K", inator.SeverityInfo, nil, `"moved to another file"`),
+	}
+	diff := inator.DiffSearchLists(old, new)
+
+	if diff.NumUnchanged != 2 {
+		t.Errorf("expected 2 unchanged statements, got %d", diff.NumUnchanged)
+	}
+	expected := map[inator.ChangeKind]int{
+		inator.ChangeAdded:     1,
+		inator.ChangeRemoved:   1,
+		inator.ChangeMoved:     1,
+		inator.ChangeSeverity:  1,
+		inator.ChangeVerbosity: 1,
+		inator.ChangeReworded:  1,
+	}
+	for kind, count := range expected {
+		if actual := diff.Count(kind); actual != count {
+			t.Errorf("expected %d %s changes, got %d", count, kind, actual)
+		}
+	}
+	for _, change := range diff.Changes {
+		if change.Has(inator.ChangeReworded) && change.Old.LineNumber != 50 {
+			t.Errorf("unexpected reworded statement %+v", change.Old)
+		}
+	}
+}
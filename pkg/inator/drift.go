@@ -0,0 +1,90 @@
+package inator
+
+import (
+	"strconv"
+	"strings"
+)
+
+// driftIndex finds statements near a logged line number, for matching logs
+// from a binary built a few commits away from the search list.
+type driftIndex struct {
+	maxDrift int
+	// statements by short source file and logged severity
+	statements map[string][]*LogStatement
+	messages   messageMatchers
+}
+
+func newDriftIndex(sm SearchMap, messages messageMatchers, maxDrift int) *driftIndex {
+	idx := &driftIndex{
+		maxDrift:   maxDrift,
+		statements: map[string][]*LogStatement{},
+		messages:   messages,
+	}
+	for _, stmts := range sm {
+		for _, stmt := range stmts {
+			if stmt.DynamicFormat {
+				continue
+			}
+			key := driftKey(stmt.ShortSourceFile(), int32(stmt.LoggedSeverity()))
+			idx.statements[key] = append(idx.statements[key], stmt)
+		}
+	}
+	return idx
+}
+
+func driftKey(file string, severity int32) string {
+	return file + ":" + strconv.Itoa(int(severity))
+}
+
+// lookup returns the statement closest to the logged line (within maxDrift
+// lines) whose format string matches the logged message, or nil.
+func (idx *driftIndex) lookup(p ParsedLog) *LogStatement {
+	var best *LogStatement
+	bestDistance := idx.maxDrift + 1
+	for _, stmt := range idx.statements[driftKey(p.ShortSourceFile(), p.Severity)] {
+		d := stmt.LineNumber - p.LineNumber
+		if d < 0 {
+			d = -d
+		}
+		if d >= bestDistance || !idx.messages.match(stmt, p.Message) {
+			continue
+		}
+		best, bestDistance = stmt, d
+	}
+	return best
+}
+
+// formatMatches reports whether a message could have been produced by the
+// given quoted format string: every piece of literal text between the
+// formatting verbs must occur in the message, in order. Format strings
+// without any literal text never match.
+func formatMatches(format, message string) bool {
+	unquoted, err := strconv.Unquote(format)
+	if err != nil {
+		return false
+	}
+	literals := formatLiterals(unquoted)
+	if len(literals) == 0 {
+		return false
+	}
+	for _, lit := range literals {
+		i := strings.Index(message, lit)
+		if i < 0 {
+			return false
+		}
+		message = message[i+len(lit):]
+	}
+	return true
+}
+
+// formatLiterals splits a printf-style format string into the literal text
+// around its verbs. %% is kept as a literal percent sign.
+func formatLiterals(format string) []string {
+	var literals []string
+	for _, piece := range parseFormat(format) {
+		if s := strings.TrimSpace(piece.text); !piece.verb && s != "" {
+			literals = append(literals, s)
+		}
+	}
+	return literals
+}
@@ -0,0 +1,79 @@
+package inator
+
+import "testing"
+
+func TestFormatMatches(t *testing.T) {
+	cases := []struct {
+		format, message string
+		expected        bool
+	}{
+		{`"Starting controller %s"`, "Starting controller nodes", true},
+		{`"synced %d of %d pods (%.1f%%)"`, "synced 3 of 4 pods (75.0%)", true},
+		{`"synced %d of %d pods"`, "pods synced: 3 of 4", false},
+		{"`raw %v string`", "raw 1 string", true},
+		{`"Pod updated"`, `"Pod updated" pod="kube-system/dns"`, true},
+		{`"%s"`, "anything", false},
+		{`fmt.Sprintf("x")`, "x", false},
+	}
+	for _, c := range cases {
+		if actual := formatMatches(c.format, c.message); actual != c.expected {
+			t.Errorf("%s / %s: expected %v, got %v", c.format, c.message, c.expected, actual)
+		}
+	}
+}
+
+func TestDriftIndexLookup(t *testing.T) {
+	sm := SearchMap{}
+	for _, stmt := range []*LogStatement{
+		{SourceFile: "pkg/a/a.go", LineNumber: 10, FormatString: `"starting %s"`},
+		{SourceFile: "pkg/a/a.go", LineNumber: 20, FormatString: `"starting %s"`},
+		{SourceFile: "pkg/a/a.go", LineNumber: 30, FormatString: `"stopping"`},
+	} {
+		sm[stmt.Fingerprint()] = append(sm[stmt.Fingerprint()], stmt)
+	}
+	idx := newDriftIndex(sm, compileMessageMatchers(sm), 5)
+	if stmt := idx.lookup(ParsedLog{SourceFile: "a/a.go", LineNumber: 18, Message: "starting x"}); stmt == nil || stmt.LineNumber != 20 {
+		t.Errorf("expected statement at line 20, got %+v", stmt)
+	}
+	if stmt := idx.lookup(ParsedLog{SourceFile: "a/a.go", LineNumber: 26, Message: "starting x"}); stmt != nil {
+		t.Errorf("expected no statement, got %+v", stmt)
+	}
+	if stmt := idx.lookup(ParsedLog{SourceFile: "a/a.go", LineNumber: 30, Severity: 2, Message: "stopping"}); stmt != nil {
+		t.Errorf("expected severity to be compared, got %+v", stmt)
+	}
+}
+
+func TestMatcherDrift(t *testing.T) {
+	list := SearchList{
+		{SourceFile: "pkg/pod/pod.go", LineNumber: 10, FormatString: `"%s is ready"`, MessageStyle: MessagePrintf},
+		{SourceFile: "pkg/pod/pod.go", LineNumber: 20, FormatString: `"Starting server"`, MessageStyle: MessagePrint},
+	}
+	sm, _ := list.GenerateSearchMap()
+	messages := compileMessageMatchers(sm)
+	// lines as read from an archive, without a line ending, logged a few
+	// lines away from the statements
+	lines := []string{
+		"I1105 13:30:39.614388  739568 pod/pod.go:12] nginx is ready",
+		"I1105 13:30:39.614388  739568 pod/pod.go:17] Starting server",
+		"I1105 13:30:39.614388  739568 pod/pod.go:40] nginx is ready",
+	}
+	parsed := make(chan ParsedLog, len(lines))
+	for _, line := range lines {
+		p, ok := ParseLine([]byte(line))
+		if !ok {
+			t.Fatalf("%q: not parsed", line)
+		}
+		parsed <- p
+	}
+	close(parsed)
+	counters := &matchCounters{}
+	hit, _, _ := matcher(sm, newDriftIndex(sm, messages, 5), messages, counters, parsed)
+	for _, stmt := range list {
+		if hits := hit[stmt]; hits == nil || len(*hits) != 1 {
+			t.Errorf("line %d: expected one drifted hit, got %v", stmt.LineNumber, hits)
+		}
+	}
+	if counters.driftMatched.Load() != 2 || counters.notMatched.Load() != 1 {
+		t.Errorf("got %d drift matched, %d not matched, want 2, 1", counters.driftMatched.Load(), counters.notMatched.Load())
+	}
+}
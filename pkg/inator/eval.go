@@ -0,0 +1,219 @@
+package inator
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strconv"
+)
+
+// packageConsts maps the names of the constants declared at package level in
+// the files of a package to their declarations, so that constants declared in
+// another file of the package can be evaluated without type information.
+// Constants of other packages cannot be evaluated this way.
+type packageConsts map[string]*ast.ValueSpec
+
+func collectConsts(files []*ast.File) packageConsts {
+	consts := packageConsts{}
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for _, name := range vs.Names {
+					consts[name.Name] = vs
+				}
+			}
+		}
+	}
+	return consts
+}
+
+// fold evaluates a constant expression without type information. It
+// understands literals, arithmetic, parentheses, conversions to named or
+// builtin types (e.g. klog.Level(4)), constants declared in the same file,
+// and the package-level constants in c, which may be declared in other files
+// of the package. It returns nil if the expression is not constant or cannot
+// be evaluated this way.
+func (c packageConsts) fold(expr ast.Expr, depth int) constant.Value {
+	if depth > 16 {
+		return nil
+	}
+	depth++
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		v := constant.MakeFromLiteral(e.Value, e.Kind, 0)
+		if v.Kind() == constant.Unknown {
+			return nil
+		}
+		return v
+	case *ast.ParenExpr:
+		return c.fold(e.X, depth)
+	case *ast.UnaryExpr:
+		x := c.fold(e.X, depth)
+		if x == nil {
+			return nil
+		}
+		switch e.Op {
+		case token.ADD, token.SUB, token.XOR, token.NOT:
+			return constant.UnaryOp(e.Op, x, 0)
+		}
+		return nil
+	case *ast.BinaryExpr:
+		x := c.fold(e.X, depth)
+		y := c.fold(e.Y, depth)
+		if x == nil || y == nil {
+			return nil
+		}
+		return binaryOp(x, e.Op, y)
+	case *ast.CallExpr:
+		// Only conversions such as klog.Level(4) or int32(4) are constant.
+		if len(e.Args) != 1 || !isConversion(e.Fun) {
+			return nil
+		}
+		return c.fold(e.Args[0], depth)
+	case *ast.Ident:
+		var spec *ast.ValueSpec
+		if e.Obj != nil {
+			if e.Obj.Kind != ast.Con {
+				return nil
+			}
+			spec, _ = e.Obj.Decl.(*ast.ValueSpec)
+		} else {
+			// The parser only resolves identifiers declared in the same
+			// file.
+			spec = c[e.Name]
+		}
+		if spec == nil {
+			return nil
+		}
+		for i, name := range spec.Names {
+			if name.Name == e.Name && i < len(spec.Values) {
+				return c.fold(spec.Values[i], depth)
+			}
+		}
+	}
+	return nil
+}
+
+func binaryOp(x constant.Value, op token.Token, y constant.Value) (result constant.Value) {
+	// constant.BinaryOp panics on operands of mismatched kinds
+	defer func() {
+		if recover() != nil {
+			result = nil
+		}
+	}()
+	switch op {
+	case token.SHL, token.SHR:
+		s, ok := constant.Uint64Val(y)
+		if !ok {
+			return nil
+		}
+		return constant.Shift(x, op, uint(s))
+	case token.QUO:
+		if x.Kind() == constant.Int && y.Kind() == constant.Int {
+			if constant.Sign(y) == 0 {
+				return nil
+			}
+			op = token.QUO_ASSIGN // integer division
+		}
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		return constant.MakeBool(constant.Compare(x, op, y))
+	}
+	return constant.BinaryOp(x, op, y)
+}
+
+// isConversion reports whether fun looks like a type name that can be used
+// in a conversion, without type information.
+func isConversion(fun ast.Expr) bool {
+	switch f := fun.(type) {
+	case *ast.ParenExpr:
+		return isConversion(f.X)
+	case *ast.Ident:
+		if f.Obj != nil {
+			return f.Obj.Kind == ast.Typ
+		}
+		obj := types.Universe.Lookup(f.Name)
+		_, ok := obj.(*types.TypeName)
+		return ok
+	case *ast.SelectorExpr:
+		// Package-qualified names such as klog.Level. Types are capitalized
+		// like any exported function, so only accept well-known ones.
+		return f.Sel.Name == "Level"
+	}
+	return false
+}
+
+// evalInt evaluates a constant integer expression.
+func evalInt(expr ast.Expr, eval func(ast.Expr) constant.Value) (int, bool) {
+	v := eval(expr)
+	if v == nil {
+		return 0, false
+	}
+	v = constant.ToInt(v)
+	if v.Kind() != constant.Int {
+		return 0, false
+	}
+	n, exact := constant.Int64Val(v)
+	if !exact {
+		return 0, false
+	}
+	return int(n), true
+}
+
+// evalVerbosity computes the V-level from the arguments passed to V().
+// If any of them is not a constant integer, ok is false.
+func evalVerbosity(exprs []ast.Expr, eval func(ast.Expr) constant.Value) (verbosity int, ok bool) {
+	for _, expr := range exprs {
+		n, ok := evalInt(expr, eval)
+		if !ok {
+			return 0, false
+		}
+		verbosity += n
+	}
+	return verbosity, true
+}
+
+// evalFormat returns the format string (or message) argument of a log call
+// as a quoted Go string. String literals are kept as written, and constant
+// expressions such as identifiers, qualified constants or concatenations are
+// replaced by their value. If the argument is not a constant string, the
+// source text of the expression is returned instead and dynamic is true.
+func evalFormat(expr ast.Expr, eval func(ast.Expr) constant.Value) (format string, dynamic bool) {
+	if lit, ok := expr.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+		return lit.Value, false
+	}
+	if v := eval(expr); v != nil && v.Kind() == constant.String {
+		return strconv.Quote(constant.StringVal(v)), false
+	}
+	return types.ExprString(expr), true
+}
+
+// evalKeysAndValues returns the key/value pairs passed to a structured log
+// call, starting at argument index start. A trailing argument expanded with
+// `...` cannot be split into pairs and is ignored.
+func evalKeysAndValues(call *ast.CallExpr, start int, eval func(ast.Expr) constant.Value) []KeyValue {
+	args := call.Args[start:]
+	if call.Ellipsis.IsValid() {
+		args = args[:len(args)-1]
+	}
+	kvs := make([]KeyValue, 0, (len(args)+1)/2)
+	for i := 0; i < len(args); i += 2 {
+		var kv KeyValue
+		if v := eval(args[i]); v != nil && v.Kind() == constant.String {
+			kv.Key = constant.StringVal(v)
+		} else {
+			kv.Key = types.ExprString(args[i])
+			kv.DynamicKey = true
+		}
+		if i+1 < len(args) {
+			kv.Value = types.ExprString(args[i+1])
+		}
+		kvs = append(kvs, kv)
+	}
+	return kvs
+}
@@ -0,0 +1,91 @@
+package inator
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"testing"
+)
+
+// foldExpr evaluates expressions without any package-level constants.
+func foldExpr(expr ast.Expr) constant.Value {
+	return packageConsts(nil).fold(expr, 0)
+}
+
+func parseCall(t *testing.T, src string) *ast.CallExpr {
+	t.Helper()
+	expr, err := parser.ParseExpr(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return expr.(*ast.CallExpr)
+}
+
+func TestEvalVerbosity(t *testing.T) {
+	cases := map[string]struct {
+		verbosity int
+		ok        bool
+	}{
+		`klog.V(2)`:                  {2, true},
+		`klog.V(klog.Level(4))`:      {4, true},
+		`klog.V((1 << 2) + 1)`:       {5, true},
+		`klog.V(7 / 2)`:              {3, true},
+		`klog.V(int32(-1))`:          {-1, true},
+		`klog.V(level)`:              {0, false},
+		`klog.V(klog.Level(n + 1))`:  {0, false},
+		`klog.V(computeLevel(1, 2))`: {0, false},
+	}
+	for src, expected := range cases {
+		call := parseCall(t, src)
+		v, ok := evalVerbosity(call.Args, foldExpr)
+		if ok != expected.ok || v != expected.verbosity {
+			t.Errorf("%s: expected (%d, %v), got (%d, %v)", src, expected.verbosity, expected.ok, v, ok)
+		}
+	}
+}
+
+func TestEvalFormat(t *testing.T) {
+	cases := map[string]struct {
+		format  string
+		dynamic bool
+	}{
+		`klog.Info("literal")`:                {`"literal"`, false},
+		"klog.Info(`raw`)":                    {"`raw`", false},
+		`klog.Info("a" + "b")`:                {`"ab"`, false},
+		`klog.Info(message)`:                  {`message`, true},
+		`klog.Info(fmt.Sprintf("x %d", 1))`:   {`fmt.Sprintf("x %d", 1)`, true},
+		`klog.Info(pkg.Message + " suffix")`:  {`pkg.Message + " suffix"`, true},
+		`klog.Info(strings.Repeat("a", 100))`: {`strings.Repeat("a", 100)`, true},
+	}
+	for src, expected := range cases {
+		call := parseCall(t, src)
+		format, dynamic := evalFormat(call.Args[0], foldExpr)
+		if format != expected.format || dynamic != expected.dynamic {
+			t.Errorf("%s: expected (%s, %v), got (%s, %v)", src, expected.format, expected.dynamic, format, dynamic)
+		}
+	}
+}
+
+func TestEvalKeysAndValues(t *testing.T) {
+	call := parseCall(t, `klog.InfoS("msg", "pod", klog.KObj(pod), "count", n+1, keyName, 1, "missing")`)
+	kvs := evalKeysAndValues(call, 1, foldExpr)
+	expected := []KeyValue{
+		{Key: "pod", Value: "klog.KObj(pod)"},
+		{Key: "count", Value: "n + 1"},
+		{Key: "keyName", DynamicKey: true, Value: "1"},
+		{Key: "missing"},
+	}
+	if len(kvs) != len(expected) {
+		t.Fatalf("expected %d pairs, got %d", len(expected), len(kvs))
+	}
+	for i := range expected {
+		if kvs[i] != expected[i] {
+			t.Errorf("pair %d: expected %+v, got %+v", i, expected[i], kvs[i])
+		}
+	}
+
+	call = parseCall(t, `klog.InfoS("msg", "a", 1, kvs...)`)
+	if kvs := evalKeysAndValues(call, 1, foldExpr); len(kvs) != 1 || kvs[0].Key != "a" {
+		t.Errorf("expected spread argument to be ignored, got %+v", kvs)
+	}
+}
@@ -0,0 +1,161 @@
+package inator
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MessageStyle is how a logging function turns its arguments into the logged
+// message.
+type MessageStyle string
+
+const (
+	// The message is fmt.Sprintf(format, args...), as in klog.Infof.
+	MessagePrintf MessageStyle = "printf"
+	// The message is fmt.Sprint(args...), as in klog.Info.
+	MessagePrint MessageStyle = "print"
+	// The message is fmt.Sprintln(args...), as in klog.Infoln.
+	MessagePrintln MessageStyle = "println"
+	// The message is quoted and followed by key/value pairs, as in
+	// klog.InfoS.
+	MessageStructured MessageStyle = "structured"
+)
+
+func (m MessageStyle) valid() bool {
+	switch m {
+	case "", MessagePrintf, MessagePrint, MessagePrintln, MessageStructured:
+		return true
+	}
+	return false
+}
+
+// messageStyle returns the message style of the function. Unless it is set
+// explicitly, structured functions are structured, and otherwise functions
+// whose name ends in f (Infof, InfofDepth) are printf-style and functions
+// whose name ends in ln (Infoln, InfolnDepth) are println-style, ignoring a
+// Depth suffix.
+func (fn FunctionSpec) messageStyle() MessageStyle {
+	name := strings.TrimSuffix(fn.Name, "Depth")
+	switch {
+	case fn.Message != "":
+		return fn.Message
+	case fn.Structured:
+		return MessageStructured
+	case strings.HasSuffix(name, "f"):
+		return MessagePrintf
+	case strings.HasSuffix(name, "ln"):
+		return MessagePrintln
+	default:
+		return MessagePrint
+	}
+}
+
+// MessageMatcher checks whether a logged message could have been produced by
+// a log statement.
+type MessageMatcher struct {
+	re *regexp.Regexp
+}
+
+// CompileMessageMatcher returns a matcher for the messages logged by the
+// statement, or nil if they cannot be checked, because the format string is
+// dynamic or the message style of the statement is not known (e.g. in search
+// lists written before it was recorded).
+//
+// Only the first line of a message is checked, since log archives are
+// matched line by line. Printf-style messages must match the whole format
+// string, with each verb matching any text. Other messages must start with
+// the message (quoted, for structured statements), since the remaining
+// arguments are appended to it.
+func CompileMessageMatcher(stmt *LogStatement) *MessageMatcher {
+	if stmt.DynamicFormat || stmt.MessageStyle == "" {
+		return nil
+	}
+	format, err := strconv.Unquote(stmt.FormatString)
+	if err != nil {
+		return nil
+	}
+	var expr strings.Builder
+	expr.WriteString("^")
+	switch stmt.MessageStyle {
+	case MessagePrintf:
+		format = strings.TrimSuffix(format, "\n")
+		firstLine, _, multiline := strings.Cut(format, "\n")
+		for _, piece := range parseFormat(firstLine) {
+			if piece.verb {
+				expr.WriteString(".*?")
+			} else {
+				expr.WriteString(regexp.QuoteMeta(piece.text))
+			}
+		}
+		if multiline {
+			break
+		}
+		// fmt reports extra arguments at the end of the message.
+		expr.WriteString(`(?:%!\(EXTRA .*\))?$`)
+	case MessageStructured:
+		expr.WriteString(regexp.QuoteMeta(strconv.Quote(format)))
+		expr.WriteString("(?: |$)")
+	case MessagePrint, MessagePrintln:
+		firstLine, _, multiline := strings.Cut(format, "\n")
+		expr.WriteString(regexp.QuoteMeta(firstLine))
+		if !multiline && stmt.MessageStyle == MessagePrintln {
+			expr.WriteString("(?: |$)")
+		}
+	default:
+		return nil
+	}
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil
+	}
+	return &MessageMatcher{re: re}
+}
+
+// Match reports whether the message could have been logged by the statement
+// the matcher was compiled for.
+func (m *MessageMatcher) Match(message string) bool {
+	return m.re.MatchString(message)
+}
+
+// formatPiece is either literal text or a verb of a format string.
+type formatPiece struct {
+	text string
+	verb bool
+}
+
+// parseFormat splits a printf-style format string into literal text and
+// verbs, including their flags, width, precision and argument indexes. %% is
+// part of the literal text, as a single percent sign.
+func parseFormat(format string) []formatPiece {
+	var pieces []formatPiece
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			pieces = append(pieces, formatPiece{text: literal.String()})
+			literal.Reset()
+		}
+	}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			literal.WriteByte(format[i])
+			continue
+		}
+		if i+1 < len(format) && format[i+1] == '%' {
+			literal.WriteByte('%')
+			i++
+			continue
+		}
+		flush()
+		start := i
+		for i++; i < len(format) && strings.IndexByte("+-# 0123456789.*[]", format[i]) >= 0; i++ {
+		}
+		end := i + 1
+		if end > len(format) {
+			end = len(format)
+		}
+		pieces = append(pieces, formatPiece{text: format[start:end], verb: true})
+	}
+	flush()
+	return pieces
+}
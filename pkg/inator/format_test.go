@@ -0,0 +1,76 @@
+package inator
+
+import "testing"
+
+func TestMessageStyle(t *testing.T) {
+	cases := map[string]MessageStyle{
+		"Info":        MessagePrint,
+		"InfoDepth":   MessagePrint,
+		"Infof":       MessagePrintf,
+		"InfofDepth":  MessagePrintf,
+		"Infoln":      MessagePrintln,
+		"InfolnDepth": MessagePrintln,
+		"InfoS":       MessageStructured,
+		"InfoSDepth":  MessageStructured,
+	}
+	for _, fn := range klogFunctions {
+		if want, ok := cases[fn.Name]; ok {
+			if got := fn.messageStyle(); got != want {
+				t.Errorf("%s: got %q, want %q", fn.Name, got, want)
+			}
+		}
+	}
+	fn := FunctionSpec{Name: "DebugfDepth", Depth: true}
+	if got := fn.messageStyle(); got != MessagePrintf {
+		t.Errorf("%s: got %q, want %q", fn.Name, got, MessagePrintf)
+	}
+	fn = FunctionSpec{Name: "Debugf", Message: MessagePrint}
+	if got := fn.messageStyle(); got != MessagePrint {
+		t.Errorf("explicit message style: got %q", got)
+	}
+}
+
+func TestMessageMatcher(t *testing.T) {
+	cases := []struct {
+		style    MessageStyle
+		format   string
+		message  string
+		expected bool
+	}{
+		{MessagePrintf, `"pod %s/%s is ready"`, "pod default/nginx is ready", true},
+		{MessagePrintf, `"pod %s/%s is ready"`, "pod default/nginx is ready!", false},
+		{MessagePrintf, `"pod %s/%s is ready"`, "node nginx is ready", false},
+		{MessagePrintf, `"100%% done after %v\n"`, "100% done after 3s", true},
+		{MessagePrintf, `"%d items"`, "3 items%!(EXTRA string=x)", true},
+		{MessagePrintf, `"first line: %v\nsecond line"`, "first line: x", true},
+		{MessagePrintf, "`raw [%s]`", "raw [x]", true},
+		{MessagePrint, `"Starting server"`, "Starting server", true},
+		{MessagePrint, `"Starting server"`, "Starting server8080", true},
+		{MessagePrint, `"Starting server"`, "Stopping server", false},
+		{MessagePrintln, `"Starting"`, "Starting server", true},
+		{MessagePrintln, `"Starting"`, "Startingserver", false},
+		{MessageStructured, `"Pod is ready"`, `"Pod is ready" pod="default/nginx"`, true},
+		{MessageStructured, `"Pod is ready"`, `"Pod is ready"`, true},
+		{MessageStructured, `"Pod is ready"`, `Pod is ready`, false},
+		{MessageStructured, `"Pod is ready"`, `"Pod is not ready" pod="default/nginx"`, false},
+	}
+	for _, c := range cases {
+		m := CompileMessageMatcher(&LogStatement{FormatString: c.format, MessageStyle: c.style})
+		if m == nil {
+			t.Errorf("%s %s: no matcher", c.style, c.format)
+			continue
+		}
+		if got := m.Match(c.message); got != c.expected {
+			t.Errorf("%s %s: Match(%q) = %v, want %v", c.style, c.format, c.message, got, c.expected)
+		}
+	}
+
+	for _, stmt := range []*LogStatement{
+		{FormatString: `"no style"`},
+		{FormatString: "fmt.Sprintf(format, args...)", DynamicFormat: true, MessageStyle: MessagePrintf},
+	} {
+		if CompileMessageMatcher(stmt) != nil {
+			t.Errorf("%+v: expected no matcher", stmt)
+		}
+	}
+}
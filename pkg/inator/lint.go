@@ -0,0 +1,203 @@
+package inator
+
+import (
+	"fmt"
+	"go/ast"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// LintCheck identifies a kind of klog misuse reported by Lint.
+type LintCheck string
+
+const (
+	// The number of arguments of a printf-style call does not match the
+	// verbs in its format string.
+	LintPrintfArgs LintCheck = "printf-args"
+	// A structured call has an odd number of key/value arguments.
+	LintOddKeysAndValues LintCheck = "odd-keys-and-values"
+	// A structured call has a key which is not a constant string.
+	LintNonConstantKey LintCheck = "non-constant-key"
+	// ErrorS (or logr's Error) is called with a nil error.
+	LintNilError LintCheck = "nil-error"
+	// The message of a call which is not printf-style contains a formatting
+	// verb, as in klog.Info("pod %s", name).
+	LintFormatVerb LintCheck = "format-verb"
+	// A package other than main calls Fatal or Exit, which exits the process
+	// on behalf of the caller.
+	LintFatalInLibrary LintCheck = "fatal-in-library"
+)
+
+// LintChecks lists all checks run by Lint.
+var LintChecks = []LintCheck{
+	LintPrintfArgs,
+	LintOddKeysAndValues,
+	LintNonConstantKey,
+	LintNilError,
+	LintFormatVerb,
+	LintFatalInLibrary,
+}
+
+// LintFinding is a misuse of klog found by Lint.
+type LintFinding struct {
+	Check      LintCheck `json:"check"`
+	SourceFile string    `json:"sourceFile"`
+	LineNumber int       `json:"lineNumber"`
+	Column     int       `json:"column"`
+	Package    string    `json:"package,omitempty"`
+	Function   string    `json:"function,omitempty"`
+	Message    string    `json:"message"`
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", f.SourceFile, f.LineNumber, f.Column, f.Message, f.Check)
+}
+
+// LintResult contains the findings of Lint, sorted by location, and the
+// diagnostics of the underlying search.
+type LintResult struct {
+	Findings    []LintFinding `json:"findings"`
+	Diagnostics []Diagnostic  `json:"diagnostics,omitempty"`
+}
+
+// Lint searches the packages matching the given patterns like Search, and
+// reports misuses of the logging functions found along the way. The search
+// cache is not used, since the checks need the syntax of every call.
+func Lint(patterns []string, opts ...SearchOption) (*LintResult, error) {
+	l := &linter{seen: map[string]bool{}}
+	opts = append(opts, func(o *SearchOptions) {
+		o.linter = l
+	})
+	result, err := Search(patterns, opts...)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(l.findings, func(i, j int) bool {
+		a, b := l.findings[i], l.findings[j]
+		if a.SourceFile != b.SourceFile {
+			return a.SourceFile < b.SourceFile
+		}
+		if a.LineNumber != b.LineNumber {
+			return a.LineNumber < b.LineNumber
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return a.Check < b.Check
+	})
+	return &LintResult{
+		Findings:    l.findings,
+		Diagnostics: result.Diagnostics,
+	}, nil
+}
+
+// linter collects findings from concurrently searched packages. The same
+// call is seen once per build configuration (and package variant), but only
+// reported once.
+type linter struct {
+	mu       sync.Mutex
+	seen     map[string]bool
+	findings []LintFinding
+}
+
+func (l *linter) report(sf *searchedFile, call *ast.CallExpr, stmt *LogStatement, check LintCheck, format string, args ...interface{}) {
+	pos := sf.fset.Position(call.Pos())
+	f := LintFinding{
+		Check:      check,
+		SourceFile: sf.relPath,
+		LineNumber: pos.Line,
+		Column:     pos.Column,
+		Package:    sf.pkg.PkgPath,
+		Function:   stmt.QualifiedFunction(),
+		Message:    fmt.Sprintf(format, args...),
+	}
+	key := fmt.Sprintf("%s:%d:%d:%s", f.SourceFile, f.LineNumber, f.Column, f.Message)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.seen[key] {
+		l.seen[key] = true
+		l.findings = append(l.findings, f)
+	}
+}
+
+// check runs every check on a call found by searchFile.
+func (l *linter) check(sf *searchedFile, call *ast.CallExpr, lc logCall, stmt *LogStatement) {
+	meta := lc.meta
+	if len(call.Args) <= meta.FormatStringPos {
+		return
+	}
+	args := call.Args[meta.FormatStringPos+1:]
+	variadic := call.Ellipsis.IsValid()
+	format, err := strconv.Unquote(stmt.FormatString)
+	constFormat := !stmt.DynamicFormat && err == nil
+
+	switch meta.Message {
+	case MessagePrintf:
+		if constFormat && !variadic {
+			if want, ok := formatArgCount(format); ok && want != len(args) {
+				l.report(sf, call, stmt, LintPrintfArgs, "format %s reads %d arguments, but the call has %d", stmt.FormatString, want, len(args))
+			}
+		}
+	case MessagePrint, MessagePrintln:
+		if constFormat {
+			if verb := firstVerb(format); verb != "" {
+				l.report(sf, call, stmt, LintFormatVerb, "message %s contains the formatting verb %s, but the function is not printf-style", stmt.FormatString, verb)
+			}
+		}
+	case MessageStructured:
+		if !variadic && len(args)%2 != 0 {
+			l.report(sf, call, stmt, LintOddKeysAndValues, "odd number of key/value arguments (%d)", len(args))
+		}
+		for _, kv := range stmt.KeysAndValues {
+			if kv.DynamicKey {
+				l.report(sf, call, stmt, LintNonConstantKey, "key %s is not a constant string", kv.Key)
+			}
+		}
+		if meta.Severity == int32(SeverityError) && meta.FormatStringPos > 0 {
+			if id, ok := call.Args[meta.FormatStringPos-1].(*ast.Ident); ok && id.Name == "nil" {
+				l.report(sf, call, stmt, LintNilError, "nil error passed to an error logging function, use InfoS instead")
+			}
+		}
+	}
+
+	if meta.Severity == int32(SeverityFatal) && sf.pkg.Name != "main" && !strings.HasSuffix(sf.relPath, "_test.go") {
+		l.report(sf, call, stmt, LintFatalInLibrary, "package %s exits the process, return an error to the caller instead", sf.pkg.Name)
+	}
+}
+
+// printfVerbs are the verbs understood by fmt.
+const printfVerbs = "bcdeEfFgGoOpqstTUvxX"
+
+// formatArgCount returns the number of arguments read by a format string, or
+// false if it cannot be determined because the format uses explicit argument
+// indexes.
+func formatArgCount(format string) (int, bool) {
+	n := 0
+	for _, piece := range parseFormat(format) {
+		if !piece.verb || len(piece.text) == 1 {
+			// A trailing % does not read an argument.
+			continue
+		}
+		if strings.Contains(piece.text, "[") {
+			return 0, false
+		}
+		// * reads the width or precision from an argument.
+		n += 1 + strings.Count(piece.text, "*")
+	}
+	return n, true
+}
+
+// firstVerb returns the first formatting verb in the message, or an empty
+// string if there is none. Like go vet, verbs with a space flag are ignored,
+// since "100% done" is more likely prose than a format string.
+func firstVerb(message string) string {
+	for _, piece := range parseFormat(message) {
+		if piece.verb && len(piece.text) > 1 && !strings.Contains(piece.text, " ") &&
+			strings.IndexByte(printfVerbs, piece.text[len(piece.text)-1]) >= 0 {
+			return piece.text
+		}
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package inator
+
+import "testing"
+
+func TestLintChecks(t *testing.T) {
+	src := `package server
+
+import "k8s.io/klog/v2"
+
+func run(name string, err error, args []interface{}) {
+	klog.Infof("starting %s on port %d", name)
+	klog.Infof("starting %s on port %*d", name, 8, 80)
+	klog.Infof("%[1]s %[1]s", name)
+	klog.Infof("starting %s", args...)
+	klog.InfoS("started", "server")
+	klog.InfoS("started", name, "x")
+	klog.ErrorS(nil, "failed", "server", name)
+	klog.ErrorS(err, "failed", "server", name)
+	klog.Info("starting %s", name)
+	klog.Info("100% done")
+	klog.Fatal("giving up")
+}
+`
+	l := &linter{seen: map[string]bool{}}
+	withLinter := func(o *SearchOptions) {
+		o.linter = l
+	}
+	searchSource(t, src, withLinter)
+	// searching the same file again, as for another build configuration,
+	// must not report anything twice
+	searchSource(t, src, withLinter)
+
+	expected := []struct {
+		check LintCheck
+		line  int
+	}{
+		{LintPrintfArgs, 6},
+		{LintOddKeysAndValues, 10},
+		{LintNonConstantKey, 11},
+		{LintNilError, 12},
+		{LintFormatVerb, 14},
+		{LintFatalInLibrary, 16},
+	}
+	if len(l.findings) != len(expected) {
+		t.Fatalf("expected %d findings, got %d: %v", len(expected), len(l.findings), l.findings)
+	}
+	for i, e := range expected {
+		if f := l.findings[i]; f.Check != e.check || f.LineNumber != e.line {
+			t.Errorf("finding %d: got %s, want %s at line %d", i, f, e.check, e.line)
+		}
+	}
+}
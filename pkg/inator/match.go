@@ -3,9 +3,11 @@ package inator
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/kralicky/klog-inator/pkg/fast"
@@ -108,7 +110,7 @@ func ParseLine(line []byte) (ls ParsedLog, ok bool) {
 		}
 	}
 
-	// dir/file:
+	// dir/file: (or a longer path, e.g. with -add_dir_header or -trimpath)
 	index := 30
 	dirStart := 30
 	var dirEnd, fileStart, fileEnd int
@@ -116,7 +118,7 @@ func ParseLine(line []byte) (ls ParsedLog, ok bool) {
 FILENAME:
 	for ; index < len(line)-1; index++ {
 		switch c := line[index]; {
-		case c == '.' || c == '-' || c == '_' ||
+		case c == '.' || c == '-' || c == '_' || c == '@' || c == '+' || c == '~' ||
 			(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
 			(c >= '0' && c <= '9'):
 		case c == '/':
@@ -161,8 +163,16 @@ LINENUMBER:
 	index++
 
 	// message
+	// Lines read from an archive have no line ending, but messages read from
+	// a JSON field usually keep it.
 	messageStart := index
-	messageEnd := len(line) - 1
+	messageEnd := len(line)
+	if messageEnd > messageStart && line[messageEnd-1] == '\n' {
+		messageEnd--
+		if messageEnd > messageStart && line[messageEnd-1] == '\r' {
+			messageEnd--
+		}
+	}
 	var err error
 	ls.LineNumber, err = strconv.Atoi(string(line[lineNumStart:lineNumEnd]))
 	if err != nil {
@@ -196,27 +206,178 @@ func scanner(lines <-chan []byte, parsedLines chan<- ParsedLog, jsonField string
 	}
 }
 
-var numMatched = atomic.NewInt64(0)
-var numNotMatched = atomic.NewInt64(0)
+// matchCounters are shared by the matchers of a single call to Match.
+type matchCounters struct {
+	matched, notMatched, driftMatched, mismatched, ambiguous atomic.Int64
+}
 
 type Matches = map[*LogStatement]*[]ParsedLog
 
-func matcher(sm SearchMap, parsed <-chan ParsedLog) Matches {
-	hit := Matches{}
+func addMatch(m Matches, stmt *LogStatement, p ParsedLog) {
+	if s, ok := m[stmt]; !ok {
+		m[stmt] = &[]ParsedLog{p}
+	} else {
+		*s = append(*s, p)
+	}
+}
+
+// matcher matches parsed logs against the search map. Logs whose message
+// does not match the format string of their statement are returned in
+// mismatched as well.
+// Logs whose message matches several statements with the same fingerprint
+// are returned in ambiguous, by fingerprint. Logs whose message matches none
+// of several statements are not matched, unless a statement is found by
+// drift.
+func matcher(sm SearchMap, drift *driftIndex, messages messageMatchers, counters *matchCounters, parsed <-chan ParsedLog) (hit, mismatched Matches, ambiguous map[string][]ParsedLog) {
+	hit = Matches{}
+	mismatched = Matches{}
+	ambiguous = map[string][]ParsedLog{}
 	for p := range parsed {
-		fp := p.Fingerprint()
-		if stmt, ok := sm[fp]; ok {
-			if s, ok := hit[stmt]; !ok {
-				hit[stmt] = &[]ParsedLog{p}
-			} else {
-				*s = append(*s, p)
+		stmt, tie := attribute(sm.Lookup(p), p, messages)
+		switch {
+		case tie:
+			fp := p.Fingerprint()
+			ambiguous[fp] = append(ambiguous[fp], p)
+			counters.ambiguous.Add(1)
+			counters.matched.Add(1)
+			continue
+		case stmt != nil:
+			if m := messages[stmt]; m != nil && !m.Match(p.Message) {
+				addMatch(mismatched, stmt, p)
+				counters.mismatched.Add(1)
+			}
+		case drift != nil:
+			if stmt = drift.lookup(p); stmt != nil {
+				counters.driftMatched.Add(1)
 			}
-			numMatched.Add(1)
+		}
+		if stmt != nil {
+			addMatch(hit, stmt, p)
+			counters.matched.Add(1)
 		} else {
-			numNotMatched.Add(1)
+			counters.notMatched.Add(1)
+		}
+	}
+	return hit, mismatched, ambiguous
+}
+
+// attribute returns the statement which wrote the log, out of the statements
+// found by SearchMap.Lookup. If there are several, the one whose format
+// string matches the message is returned. If none of them matches, it
+// returns nil, and if more than one matches, it returns nil and true.
+func attribute(candidates []*LogStatement, p ParsedLog, messages messageMatchers) (stmt *LogStatement, tie bool) {
+	if len(candidates) == 1 {
+		return candidates[0], false
+	}
+	for _, c := range candidates {
+		if messages.match(c, p.Message) {
+			if stmt != nil {
+				return nil, true
+			}
+			stmt = c
+		}
+	}
+	return stmt, false
+}
+
+// Lookup returns the statements which could have written the log: those with
+// its fingerprint whose path agrees with the path in the log. If the log
+// carries more of the path than the parent directory of the file (e.g. with
+// -add_dir_header, or in binaries built with -trimpath), the statements
+// sharing the longest path suffix with it are returned. Paths are compared
+// with both the source file of the statement and the import path of its
+// package, so that paths relative to GOPATH or to the module cache match as
+// well.
+func (sm SearchMap) Lookup(p ParsedLog) []*LogStatement {
+	candidates := sm[p.Fingerprint()]
+	if len(candidates) == 0 || strings.Count(p.SourceFile, "/") < 2 {
+		return candidates
+	}
+	logPath := pathElements(p.SourceFile)
+	var longest []*LogStatement
+	bestLen := 0
+	for _, stmt := range candidates {
+		n, ok := stmt.pathSuffixLen(logPath)
+		if !ok {
+			continue
+		}
+		if n > bestLen {
+			longest, bestLen = nil, n
+		}
+		if n == bestLen {
+			longest = append(longest, stmt)
+		}
+	}
+	return longest
+}
+
+// pathSuffixLen returns the number of trailing path elements the statement
+// shares with the path of a log, and whether the paths agree, i.e. one of
+// them ends with the other.
+func (s *LogStatement) pathSuffixLen(logPath []string) (int, bool) {
+	paths := [][]string{pathElements(s.SourceFile)}
+	if s.Package != "" {
+		paths = append(paths, append(pathElements(s.Package), filepath.Base(s.SourceFile)))
+	}
+	best, agrees := 0, false
+	for _, path := range paths {
+		n := commonSuffixLen(path, logPath)
+		if n == len(path) || n == len(logPath) {
+			agrees = true
+		}
+		if n > best {
+			best = n
+		}
+	}
+	return best, agrees
+}
+
+// pathElements splits a file path into its elements, leaving out empty and .
+// elements, and module versions (as in k8s.io/klog/v2@v2.30.0).
+func pathElements(path string) []string {
+	var elements []string
+	for _, e := range strings.Split(filepath.ToSlash(path), "/") {
+		if i := strings.IndexByte(e, '@'); i > 0 {
+			e = e[:i]
+		}
+		if e != "" && e != "." {
+			elements = append(elements, e)
+		}
+	}
+	return elements
+}
+
+func commonSuffixLen(a, b []string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	return n
+}
+
+// messageMatchers holds the compiled message matchers of the statements
+// which have one.
+type messageMatchers map[*LogStatement]*MessageMatcher
+
+func compileMessageMatchers(sm SearchMap) messageMatchers {
+	matchers := messageMatchers{}
+	for _, stmts := range sm {
+		for _, stmt := range stmts {
+			if m := CompileMessageMatcher(stmt); m != nil {
+				matchers[stmt] = m
+			}
 		}
 	}
-	return hit
+	return matchers
+}
+
+// match checks the message with the compiled matcher of the statement, or
+// with formatMatches if the statement has none.
+func (m messageMatchers) match(stmt *LogStatement, message string) bool {
+	if matcher := m[stmt]; matcher != nil {
+		return matcher.Match(message)
+	}
+	return formatMatches(stmt.FormatString, message)
 }
 
 type MatchedAndNotMatchedLogs struct {
@@ -229,10 +390,25 @@ type MatchResults struct {
 	NotMatched    []Matches
 	NumMatched    int64
 	NumNotMatched int64
+	// NumDriftMatched is the number of matched logs (included in NumMatched)
+	// which were attributed to a statement on a nearby line.
+	NumDriftMatched int64
+	// Mismatched contains the matched logs whose message does not match the
+	// format string of their statement, which means that the search list is
+	// stale or the fingerprints of two statements collided.
+	Mismatched    []Matches
+	NumMismatched int64
+	// Ambiguous contains the logs (included in NumMatched) whose message
+	// matches the format strings of several statements with their
+	// fingerprint, by fingerprint. Logs matching none of them are counted in
+	// NumNotMatched.
+	Ambiguous    map[string][]ParsedLog
+	NumAmbiguous int64
 }
 
 type MatchOptions struct {
 	jsonField string
+	maxDrift  int
 }
 
 type MatchOption func(*MatchOptions)
@@ -249,6 +425,17 @@ func WithJSONField(field string) MatchOption {
 	}
 }
 
+// WithMaxLineDrift enables matching logs from a binary built a few commits
+// away from the search list. If no statement has the exact file, line and
+// severity of a log, the statements in the same file with the same severity
+// within maxDrift lines are compared with the log message instead, and the
+// nearest one whose format string matches is used.
+func WithMaxLineDrift(maxDrift int) MatchOption {
+	return func(o *MatchOptions) {
+		o.maxDrift = maxDrift
+	}
+}
+
 func Match(sm SearchMap, archive string, opts ...MatchOption) (MatchResults, error) {
 	options := MatchOptions{}
 	options.Apply(opts...)
@@ -258,7 +445,7 @@ func Match(sm SearchMap, archive string, opts ...MatchOption) (MatchResults, err
 	channelGroups := make([]struct {
 		Lines       chan []byte
 		ParsedLines chan ParsedLog
-	}, workerCount/workersPerGroup)
+	}, max(1, workerCount/workersPerGroup))
 	s := "s"
 	if len(channelGroups) == 1 {
 		s = ""
@@ -286,7 +473,17 @@ func Match(sm SearchMap, archive string, opts ...MatchOption) (MatchResults, err
 		}
 	}()
 
-	results := make(chan Matches, workerCount)
+	messages := compileMessageMatchers(sm)
+	var drift *driftIndex
+	if options.maxDrift > 0 {
+		drift = newDriftIndex(sm, messages, options.maxDrift)
+	}
+	type matcherResult struct {
+		hit, mismatched Matches
+		ambiguous       map[string][]ParsedLog
+	}
+	results := make(chan matcherResult, workerCount)
+	counters := &matchCounters{}
 
 	for i := 0; i < workerCount; i++ {
 		go func(lines <-chan []byte, parsedLines chan<- ParsedLog) {
@@ -296,7 +493,8 @@ func Match(sm SearchMap, archive string, opts ...MatchOption) (MatchResults, err
 			channelGroups[i%len(channelGroups)].ParsedLines)
 		go func(parsedLines <-chan ParsedLog) {
 			defer matcherWg.Done()
-			results <- matcher(sm, parsedLines)
+			hit, mismatched, ambiguous := matcher(sm, drift, messages, counters, parsedLines)
+			results <- matcherResult{hit, mismatched, ambiguous}
 		}(channelGroups[i%len(channelGroups)].ParsedLines)
 	}
 
@@ -314,13 +512,24 @@ func Match(sm SearchMap, archive string, opts ...MatchOption) (MatchResults, err
 	close(results)
 
 	hit := []Matches{}
-	for match := range results {
-		hit = append(hit, match)
+	mismatched := []Matches{}
+	ambiguous := map[string][]ParsedLog{}
+	for result := range results {
+		hit = append(hit, result.hit)
+		mismatched = append(mismatched, result.mismatched)
+		for fp, logs := range result.ambiguous {
+			ambiguous[fp] = append(ambiguous[fp], logs...)
+		}
 	}
 	return MatchResults{
-		Matched:       hit,
-		NumMatched:    numMatched.Load(),
-		NumNotMatched: numNotMatched.Load(),
+		Matched:         hit,
+		NumMatched:      counters.matched.Load(),
+		NumNotMatched:   counters.notMatched.Load(),
+		NumDriftMatched: counters.driftMatched.Load(),
+		Mismatched:      mismatched,
+		NumMismatched:   counters.mismatched.Load(),
+		Ambiguous:       ambiguous,
+		NumAmbiguous:    counters.ambiguous.Load(),
 	}, nil
 }
 
@@ -340,9 +549,11 @@ func AggregateResults(results []Matches) Matches {
 
 func FindMissed(sm SearchMap, aggregated Matches) Matches {
 	missed := Matches{}
-	for _, v := range sm {
-		if _, ok := aggregated[v]; !ok {
-			missed[v] = nil
+	for _, stmts := range sm {
+		for _, v := range stmts {
+			if _, ok := aggregated[v]; !ok {
+				missed[v] = nil
+			}
 		}
 	}
 	return missed
@@ -375,35 +586,37 @@ func AnalyzeMatches(sm SearchMap, results Matches) AnalyzeResult {
 		NumErrorMissed:  make(map[int]int64),
 		PercentErrorHit: make(map[int]float64),
 	}
-	for _, v := range sm {
-		matched, ok := results[v]
-		verbosity := -1
-		if v.Verbosity != nil {
-			verbosity = *v.Verbosity
-		}
-		if !ok || matched == nil || len(*matched) == 0 {
-			result.NumMissedTotal++
-			switch v.Severity {
-			case SeverityInfo:
-				result.NumInfoMissed[verbosity]++
-			case SeverityWarning:
-				result.NumWarnMissed++
-			case SeverityError:
-				result.NumErrorMissed[verbosity]++
-			case SeverityFatal:
-				result.NumFatalMissed++
+	for _, stmts := range sm {
+		for _, v := range stmts {
+			matched, ok := results[v]
+			verbosity := -1
+			if v.Verbosity != nil {
+				verbosity = *v.Verbosity
 			}
-		} else {
-			result.NumHitTotal++
-			switch v.Severity {
-			case SeverityInfo:
-				result.NumInfoHit[verbosity]++
-			case SeverityWarning:
-				result.NumWarnHit++
-			case SeverityError:
-				result.NumErrorHit[verbosity]++
-			case SeverityFatal:
-				result.NumFatalHit++
+			if !ok || matched == nil || len(*matched) == 0 {
+				result.NumMissedTotal++
+				switch v.Severity {
+				case SeverityInfo:
+					result.NumInfoMissed[verbosity]++
+				case SeverityWarning:
+					result.NumWarnMissed++
+				case SeverityError:
+					result.NumErrorMissed[verbosity]++
+				case SeverityFatal:
+					result.NumFatalMissed++
+				}
+			} else {
+				result.NumHitTotal++
+				switch v.Severity {
+				case SeverityInfo:
+					result.NumInfoHit[verbosity]++
+				case SeverityWarning:
+					result.NumWarnHit++
+				case SeverityError:
+					result.NumErrorHit[verbosity]++
+				case SeverityFatal:
+					result.NumFatalHit++
+				}
 			}
 		}
 	}
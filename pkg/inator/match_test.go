@@ -1,7 +1,11 @@
 package inator_test
 
 import (
+	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/kralicky/klog-inator/pkg/inator"
@@ -26,3 +30,84 @@ func BenchmarkParseLineRegex(b *testing.B) {
 		rx.Match(SampleLine)
 	}
 }
+
+func TestParseLine(t *testing.T) {
+	cases := map[string]string{
+		"I1105 13:30:39.614388  739568 queueset/queueset.go:488] Sample Text\n":                                    "queueset/queueset.go",
+		"I1105 13:30:39.614388  739568 pkg/util/queueset/queueset.go:488] Sample Text\n":                           "pkg/util/queueset/queueset.go",
+		"I1105 13:30:39.614388  739568 /go/pkg/mod/k8s.io/klog/v2@v2.30.0/queueset/queueset.go:488] Sample Text\n": "/go/pkg/mod/k8s.io/klog/v2@v2.30.0/queueset/queueset.go",
+	}
+	// lines read from an archive have no line ending
+	cases["I1105 13:30:39.614388  739568 queueset/queueset.go:488] Sample Text"] = "queueset/queueset.go"
+	cases["I1105 13:30:39.614388  739568 pkg/queueset/queueset.go:488] Sample Text\r\n"] = "pkg/queueset/queueset.go"
+	for line, file := range cases {
+		parsed, ok := inator.ParseLine([]byte(line))
+		if !ok {
+			t.Errorf("%q: not parsed", line)
+			continue
+		}
+		if parsed.SourceFile != file || parsed.LineNumber != 488 || parsed.Message != "Sample Text" {
+			t.Errorf("%q: unexpected result %+v", line, parsed)
+		}
+		if parsed.ShortSourceFile() != "queueset/queueset.go" {
+			t.Errorf("%q: unexpected short source file %q", line, parsed.ShortSourceFile())
+		}
+	}
+	if _, ok := inator.ParseLine([]byte("I1105 13:30:39.614388  739568 queueset.go:488] Sample Text\n")); ok {
+		t.Error("expected a file without a directory to be rejected")
+	}
+}
+
+// matchArchive writes the lines to an archive without a trailing newline and
+// matches it against the search list.
+func matchArchive(t *testing.T, list inator.SearchList, lines []string, opts ...inator.MatchOption) inator.MatchResults {
+	t.Helper()
+	archive := filepath.Join(t.TempDir(), "archive.log")
+	if err := os.WriteFile(archive, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sm, _ := list.GenerateSearchMap()
+	results, err := inator.Match(sm, archive, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+func TestMatch(t *testing.T) {
+	list := inator.SearchList{
+		{SourceFile: "pkg/pod/pod.go", LineNumber: 10, FormatString: `"pod %s is ready"`, MessageStyle: inator.MessagePrintf},
+		{SourceFile: "pkg/pod/pod.go", LineNumber: 20, FormatString: `"Starting server"`, MessageStyle: inator.MessagePrint},
+		{SourceFile: "pkg/pod/pod.go", LineNumber: 30, FormatString: `"Pod is ready"`, MessageStyle: inator.MessageStructured},
+	}
+	results := matchArchive(t, list, []string{
+		"I1105 13:30:39.614388  739568 pod/pod.go:10] pod x is ready",
+		"I1105 13:30:39.614388  739568 pod/pod.go:20] Starting server",
+		`I1105 13:30:39.614388  739568 pod/pod.go:30] "Pod is ready"`,
+	})
+	if results.NumMatched != 3 || results.NumNotMatched != 0 || results.NumMismatched != 0 {
+		t.Errorf("got %d matched, %d not matched, %d mismatched, want 3, 0, 0",
+			results.NumMatched, results.NumNotMatched, results.NumMismatched)
+	}
+}
+
+func TestMatchReclassified(t *testing.T) {
+	original := inator.SeverityError
+	list := inator.SearchList{
+		// demoted from ErrorS to Info by a severity rule
+		{SourceFile: "pkg/pod/pod.go", LineNumber: 10, Severity: inator.SeverityInfo, OriginalSeverity: &original,
+			SeverityRule: "not-found", FormatString: `"Pod not found"`, MessageStyle: inator.MessageStructured},
+	}
+	for _, drift := range []int{0, 5} {
+		line := 10 + drift
+		results := matchArchive(t, list, []string{
+			`E1105 13:30:39.614388  739568 pod/pod.go:` + strconv.Itoa(line) + `] "Pod not found" pod="x"`,
+		}, inator.WithMaxLineDrift(drift))
+		if results.NumMatched != 1 || results.NumNotMatched != 0 {
+			t.Errorf("line %d: got %d matched, %d not matched, want 1, 0", line, results.NumMatched, results.NumNotMatched)
+		}
+		if hits := inator.AggregateResults(results.Matched)[list[0]]; hits == nil || len(*hits) != 1 {
+			t.Errorf("line %d: expected the demoted statement to be hit", line)
+		}
+	}
+}
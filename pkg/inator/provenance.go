@@ -0,0 +1,103 @@
+package inator
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// NewSearchListHeader returns the header describing a search with the given
+// patterns and options.
+func NewSearchListHeader(patterns []string, opts ...SearchOption) *SearchListHeader {
+	options := SearchOptions{}
+	options.Apply(opts...)
+
+	configs := make([]string, len(options.buildConfigs))
+	for i, config := range options.buildConfigs {
+		configs[i] = config.String()
+	}
+	return &SearchListHeader{
+		SchemaVersion: SearchListSchemaVersion,
+		GoVersion:     goVersion(options.env(BuildConfig{GOOS: options.goos, GOARCH: options.goarch})),
+		Patterns:      patterns,
+		Options: SearchListOptions{
+			BuildTags:        options.buildTags,
+			GOOS:             options.goos,
+			GOARCH:           options.goarch,
+			ModFlag:          options.modFlag,
+			ExcludeModules:   options.excludeModules,
+			ExcludeFilenames: options.excludeFilenames,
+			ErrorKeywords:    options.errorKeywords,
+			TypeCheck:        options.typeCheck,
+			BuildConfigs:     configs,
+			Tests:            options.tests,
+			Registries:       options.registries,
+			SeverityRules:    options.severityRules,
+		},
+	}
+}
+
+// goVersion returns the version of the go command used to load packages, or
+// the version this binary was built with if it cannot be run.
+func goVersion(env []string) string {
+	cmd := exec.Command("go", "env", "GOVERSION")
+	cmd.Env = env
+	out, err := cmd.Output()
+	if version := strings.TrimSpace(string(out)); err == nil && version != "" {
+		return version
+	}
+	return runtime.Version()
+}
+
+// provenance identifies where the statements of a package come from.
+type provenance struct {
+	module        string
+	moduleVersion string
+	pkgPath       string
+	revision      string
+}
+
+func newProvenance(pkg *packages.Package) provenance {
+	p := provenance{pkgPath: pkg.PkgPath}
+	if m := pkg.Module; m != nil {
+		p.module = m.Path
+		p.moduleVersion = m.Version
+		if m.Replace != nil && m.Replace.Version != "" {
+			p.moduleVersion = m.Replace.Version
+		}
+		if m.Main {
+			p.revision = vcsRevision(m.Dir)
+		}
+	}
+	return p
+}
+
+func (p provenance) apply(stmt *LogStatement) {
+	stmt.Module = p.module
+	stmt.ModuleVersion = p.moduleVersion
+	stmt.Package = p.pkgPath
+	stmt.Revision = p.revision
+}
+
+var revisions sync.Map // module directory -> revision
+
+// vcsRevision returns the git commit checked out in dir, or an empty string
+// if dir is not in a git repository.
+func vcsRevision(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	if rev, ok := revisions.Load(dir); ok {
+		return rev.(string)
+	}
+	var rev string
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err == nil {
+		rev = strings.TrimSpace(string(out))
+	}
+	revisions.Store(dir, rev)
+	return rev
+}
@@ -0,0 +1,270 @@
+package inator
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Registry describes the logging functions Search looks for. The default
+// registry contains klog (and glog) and logr; additional packages, such as
+// in-house logging facades, can be added with WithRegistry.
+type Registry struct {
+	Packages []PackageSpec `yaml:"packages" json:"packages"`
+}
+
+// PackageSpec describes the logging functions of a single package.
+type PackageSpec struct {
+	// Path is the import path of the package. Vendored copies of the package
+	// are matched as well.
+	Path      string          `yaml:"path" json:"path"`
+	Functions []FunctionSpec  `yaml:"functions" json:"functions"`
+	Verbosity []VerbositySpec `yaml:"verbosity,omitempty" json:"verbosity,omitempty"`
+}
+
+// FunctionSpec describes a function which logs a statement.
+type FunctionSpec struct {
+	Name string `yaml:"name" json:"name"`
+	// Receiver is the name of the receiver type if the function is a method,
+	// such as Verbose for klog.Verbose.Info.
+	Receiver string   `yaml:"receiver,omitempty" json:"receiver,omitempty"`
+	Severity Severity `yaml:"severity" json:"severity"`
+	// FormatPos is the index of the format string (or message) argument.
+	FormatPos int `yaml:"formatPos" json:"formatPos"`
+	// Calls with fewer arguments are ignored.
+	MinArgs int `yaml:"minArgs,omitempty" json:"minArgs,omitempty"`
+	// Structured functions take alternating keys and values after the
+	// message.
+	Structured bool `yaml:"structured,omitempty" json:"structured,omitempty"`
+	// Depth functions take the stack depth to attribute the statement to as
+	// their first argument.
+	Depth bool `yaml:"depth,omitempty" json:"depth,omitempty"`
+	// Verbosity is the fixed V-level of functions such as Debugf which always
+	// log at the same level.
+	Verbosity *int `yaml:"verbosity,omitempty" json:"verbosity,omitempty"`
+	// Message is how the function formats the message. If it is not set, it
+	// is derived from Structured and the name of the function (see
+	// MessageStyle).
+	Message MessageStyle `yaml:"message,omitempty" json:"message,omitempty"`
+}
+
+// VerbositySpec describes a function or method such as klog.V which takes a
+// V-level and returns a value whose logging methods are gated by it.
+type VerbositySpec struct {
+	Name string `yaml:"name" json:"name"`
+	// Receiver is the name of the receiver type if the function is a method.
+	// V-levels of chained method calls (logger.V(1).V(2)) are added up.
+	Receiver string `yaml:"receiver,omitempty" json:"receiver,omitempty"`
+	// Arg is the index of the V-level argument.
+	Arg int `yaml:"arg" json:"arg"`
+	// Returns is the name of the type returned by the function. Methods of
+	// this type listed in Functions are considered V-gated.
+	Returns string `yaml:"returns" json:"returns"`
+}
+
+// LoadRegistry reads a registry from a YAML or JSON file.
+func LoadRegistry(filename string) (*Registry, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	reg := &Registry{}
+	if err := yaml.UnmarshalStrict(data, reg); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, pkg := range reg.Packages {
+		if pkg.Path == "" {
+			return nil, fmt.Errorf("%s: package path must not be empty", filename)
+		}
+		for _, fn := range pkg.Functions {
+			if fn.Name == "" {
+				return nil, fmt.Errorf("%s: %s: function name must not be empty", filename, pkg.Path)
+			}
+			if !fn.Message.valid() {
+				return nil, fmt.Errorf("%s: %s.%s: unknown message style %q", filename, pkg.Path, fn.Name, fn.Message)
+			}
+		}
+	}
+	return reg, nil
+}
+
+var klogFunctions = []FunctionSpec{
+	{Name: "Info", Severity: SeverityInfo, FormatPos: 0},
+	{Name: "InfoDepth", Severity: SeverityInfo, FormatPos: 1, MinArgs: 1, Depth: true},
+	{Name: "InfofDepth", Severity: SeverityInfo, FormatPos: 1, MinArgs: 2, Depth: true},
+	{Name: "Infoln", Severity: SeverityInfo, FormatPos: 0},
+	{Name: "InfolnDepth", Severity: SeverityInfo, FormatPos: 1, MinArgs: 1, Depth: true},
+	{Name: "Infof", Severity: SeverityInfo, FormatPos: 0, MinArgs: 1},
+	{Name: "InfoS", Severity: SeverityInfo, FormatPos: 0, MinArgs: 1, Structured: true},
+	{Name: "InfoSDepth", Severity: SeverityInfo, FormatPos: 1, MinArgs: 2, Structured: true, Depth: true},
+	{Name: "Warning", Severity: SeverityWarning, FormatPos: 0},
+	{Name: "WarningDepth", Severity: SeverityWarning, FormatPos: 1, MinArgs: 1, Depth: true},
+	{Name: "WarningfDepth", Severity: SeverityWarning, FormatPos: 1, MinArgs: 2, Depth: true},
+	{Name: "Warningln", Severity: SeverityWarning, FormatPos: 0},
+	{Name: "WarninglnDepth", Severity: SeverityWarning, FormatPos: 1, MinArgs: 1, Depth: true},
+	{Name: "Warningf", Severity: SeverityWarning, FormatPos: 0, MinArgs: 1},
+	{Name: "Error", Severity: SeverityError, FormatPos: 0},
+	{Name: "ErrorDepth", Severity: SeverityError, FormatPos: 1, MinArgs: 1, Depth: true},
+	{Name: "ErrorfDepth", Severity: SeverityError, FormatPos: 1, MinArgs: 2, Depth: true},
+	{Name: "Errorln", Severity: SeverityError, FormatPos: 0},
+	{Name: "ErrorlnDepth", Severity: SeverityError, FormatPos: 1, MinArgs: 1, Depth: true},
+	{Name: "Errorf", Severity: SeverityError, FormatPos: 0, MinArgs: 1},
+	{Name: "ErrorS", Severity: SeverityError, FormatPos: 1, MinArgs: 2, Structured: true},
+	{Name: "ErrorSDepth", Severity: SeverityError, FormatPos: 2, MinArgs: 3, Structured: true, Depth: true},
+	{Name: "Fatal", Severity: SeverityFatal, FormatPos: 0},
+	{Name: "FatalDepth", Severity: SeverityFatal, FormatPos: 1, MinArgs: 1, Depth: true},
+	{Name: "FatalfDepth", Severity: SeverityFatal, FormatPos: 1, MinArgs: 2, Depth: true},
+	{Name: "Fatalln", Severity: SeverityFatal, FormatPos: 0},
+	{Name: "FatallnDepth", Severity: SeverityFatal, FormatPos: 1, MinArgs: 1, Depth: true},
+	{Name: "Fatalf", Severity: SeverityFatal, FormatPos: 0, MinArgs: 1},
+	{Name: "Exit", Severity: SeverityFatal, FormatPos: 0},
+	{Name: "ExitDepth", Severity: SeverityFatal, FormatPos: 1, MinArgs: 1, Depth: true},
+	{Name: "ExitfDepth", Severity: SeverityFatal, FormatPos: 1, MinArgs: 2, Depth: true},
+	{Name: "Exitln", Severity: SeverityFatal, FormatPos: 0},
+	{Name: "ExitlnDepth", Severity: SeverityFatal, FormatPos: 1, MinArgs: 1, Depth: true},
+	{Name: "Exitf", Severity: SeverityFatal, FormatPos: 0, MinArgs: 1},
+}
+
+// klogVerboseMethods are the methods of klog.Verbose, which are V-gated
+// versions of the package-level functions of the same name.
+var klogVerboseMethods = []string{
+	"Info", "InfoDepth", "InfofDepth", "Infoln", "InfolnDepth", "Infof",
+	"InfoS", "InfoSDepth", "ErrorS",
+}
+
+// DefaultRegistry returns a registry containing klog, the libraries it is
+// derived from, and logr.
+//
+// The Info and Error methods of logr.Logger are included with the severity
+// of the header klog writes for them when it is the logging backend: Info is
+// written with an I header (regardless of the V-level of the logger) and
+// Error is written with an E header, in the same format as the structured
+// klog.InfoS and klog.ErrorS functions.
+func DefaultRegistry() *Registry {
+	reg := &Registry{}
+	for _, path := range klogImportPaths {
+		pkg := PackageSpec{
+			Path: path,
+			Verbosity: []VerbositySpec{
+				{Name: "V", Arg: 0, Returns: "Verbose"},
+			},
+		}
+		pkg.Functions = append(pkg.Functions, klogFunctions...)
+		for _, name := range klogVerboseMethods {
+			for _, fn := range klogFunctions {
+				if fn.Name == name {
+					fn.Receiver = "Verbose"
+					pkg.Functions = append(pkg.Functions, fn)
+				}
+			}
+		}
+		reg.Packages = append(reg.Packages, pkg)
+	}
+	reg.Packages = append(reg.Packages, PackageSpec{
+		Path: logrImportPath,
+		Functions: []FunctionSpec{
+			{Name: "Info", Receiver: "Logger", Severity: SeverityInfo, FormatPos: 0, MinArgs: 1, Structured: true},
+			{Name: "Error", Receiver: "Logger", Severity: SeverityError, FormatPos: 1, MinArgs: 2, Structured: true},
+		},
+		Verbosity: []VerbositySpec{
+			{Name: "V", Receiver: "Logger", Arg: 0, Returns: "Logger"},
+		},
+	})
+	return reg
+}
+
+type funcKey struct {
+	path, recv, name string
+}
+
+type verbositySource struct {
+	arg     int
+	returns string
+}
+
+// registry is the compiled form of one or more Registries.
+type registry struct {
+	functions map[funcKey]klogFunctionMeta
+	verbosity map[funcKey]verbositySource
+	// Types whose methods are always V-gated, because they are returned by a
+	// package-level verbosity function such as klog.V.
+	gated map[funcKey]bool
+	paths map[string]bool
+	// Packages with package-level functions or verbosity sources.
+	packageFuncs map[string]bool
+}
+
+// compileRegistry merges the given registries. Later entries for the same
+// function replace earlier ones.
+func compileRegistry(registries ...*Registry) *registry {
+	r := &registry{
+		functions:    map[funcKey]klogFunctionMeta{},
+		verbosity:    map[funcKey]verbositySource{},
+		gated:        map[funcKey]bool{},
+		paths:        map[string]bool{},
+		packageFuncs: map[string]bool{},
+	}
+	for _, reg := range registries {
+		for _, pkg := range reg.Packages {
+			path := unvendor(pkg.Path)
+			r.paths[path] = true
+			for _, fn := range pkg.Functions {
+				if fn.Receiver == "" {
+					r.packageFuncs[path] = true
+				}
+				r.functions[funcKey{path, fn.Receiver, fn.Name}] = klogFunctionMeta{
+					Severity:        int32(fn.Severity),
+					FormatStringPos: fn.FormatPos,
+					MinArgs:         fn.MinArgs,
+					Structured:      fn.Structured,
+					Depth:           fn.Depth,
+					Verbosity:       fn.Verbosity,
+					Message:         fn.messageStyle(),
+				}
+			}
+			for _, v := range pkg.Verbosity {
+				r.verbosity[funcKey{path, v.Receiver, v.Name}] = verbositySource{
+					arg:     v.Arg,
+					returns: v.Returns,
+				}
+				if v.Receiver == "" {
+					r.packageFuncs[path] = true
+					r.gated[funcKey{path: path, recv: v.Returns}] = true
+				}
+			}
+		}
+	}
+	return r
+}
+
+func (r *registry) lookup(path, recv, name string) (klogFunctionMeta, bool) {
+	meta, ok := r.functions[funcKey{unvendor(path), recv, name}]
+	return meta, ok
+}
+
+func (r *registry) verbositySource(path, recv, name string) (verbositySource, bool) {
+	src, ok := r.verbosity[funcKey{unvendor(path), recv, name}]
+	return src, ok
+}
+
+func (r *registry) isGated(path, typeName string) bool {
+	return r.gated[funcKey{path: unvendor(path), recv: typeName}]
+}
+
+func (r *registry) hasPackage(path string) bool {
+	return r.paths[unvendor(path)]
+}
+
+func (r *registry) hasPackageFuncs(path string) bool {
+	return r.packageFuncs[unvendor(path)]
+}
+
+// declares reports whether name is a package-level logging function or
+// verbosity source of the package.
+func (r *registry) declares(path, name string) bool {
+	path = unvendor(path)
+	_, isFunc := r.functions[funcKey{path, "", name}]
+	_, isSource := r.verbosity[funcKey{path, "", name}]
+	return isFunc || isSource
+}
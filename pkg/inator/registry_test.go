@@ -0,0 +1,50 @@
+package inator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRegistry(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "registry.yaml")
+	err := os.WriteFile(filename, []byte(`
+packages:
+- path: example.com/log
+  functions:
+  - {name: Debugf, severity: info, formatPos: 0, minArgs: 1, verbosity: 4}
+  - {name: Warn, severity: W}
+  - {name: Printf, receiver: Logger, severity: 2}
+  verbosity:
+  - {name: V, arg: 0, returns: Logger}
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg, err := LoadRegistry(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := compileRegistry(DefaultRegistry(), reg)
+
+	if meta, ok := r.lookup("example.com/log", "", "Debugf"); !ok || meta.MinArgs != 1 || meta.Verbosity == nil || *meta.Verbosity != 4 {
+		t.Errorf("unexpected Debugf: %+v, %v", meta, ok)
+	}
+	if meta, ok := r.lookup("vendor/example.com/log", "", "Warn"); !ok || Severity(meta.Severity) != SeverityWarning {
+		t.Errorf("unexpected Warn: %+v, %v", meta, ok)
+	}
+	if meta, ok := r.lookup("example.com/log", "Logger", "Printf"); !ok || Severity(meta.Severity) != SeverityError {
+		t.Errorf("unexpected Printf: %+v, %v", meta, ok)
+	}
+	if !r.isGated("example.com/log", "Logger") {
+		t.Error("expected Logger to be V-gated")
+	}
+	if _, ok := r.lookup(klogImportPath, "Verbose", "InfoS"); !ok {
+		t.Error("expected default registry to be included")
+	}
+
+	os.WriteFile(filename, []byte(`{"packages": [{"path": "x", "functions": [{"name": "A", "severity": "bogus"}]}]}`), 0o644)
+	if _, err := LoadRegistry(filename); err == nil {
+		t.Error("expected error for unknown severity")
+	}
+}
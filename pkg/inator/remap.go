@@ -0,0 +1,297 @@
+package inator
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RemapStats summarizes the result of RemapSearchList.
+type RemapStats struct {
+	// Statements whose line number or file name changed.
+	NumMoved int
+	// Statements whose lines are the same at both commits.
+	NumUnchanged int
+	// Statements dropped because their lines (or files) were deleted or
+	// modified between the two commits.
+	NumDropped int
+	// Statements in files outside of the repository, e.g. in dependencies,
+	// which are kept unchanged.
+	NumOutside int
+}
+
+type RemapOptions struct {
+	sourceRoot string
+	from       string
+}
+
+type RemapOption func(*RemapOptions)
+
+func (o *RemapOptions) Apply(opts ...RemapOption) {
+	for _, op := range opts {
+		op(o)
+	}
+}
+
+// WithSourceRoot sets the directory the source file paths in the search list
+// are relative to, i.e. the working directory of the search. The default is
+// the current working directory.
+func WithSourceRoot(dir string) RemapOption {
+	return func(o *RemapOptions) {
+		o.sourceRoot = dir
+	}
+}
+
+// WithFromRevision sets the commit the search list was generated at. By
+// default, the revision recorded in each statement is used.
+func WithFromRevision(rev string) RemapOption {
+	return func(o *RemapOptions) {
+		o.from = rev
+	}
+}
+
+// RemapSearchList rewrites the file names and line numbers of the statements
+// in the search list to where their lines are at the given commit of a local
+// git repository, using the diff between the commit each statement was found
+// at and that commit. Statements on lines which were deleted or modified are
+// dropped. The returned statements are copies.
+func RemapSearchList(list SearchList, repo, to string, opts ...RemapOption) (SearchList, RemapStats, error) {
+	options := RemapOptions{}
+	options.Apply(opts...)
+	var stats RemapStats
+
+	if options.sourceRoot == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, stats, err
+		}
+		options.sourceRoot = wd
+	}
+	sourceRoot, err := filepath.Abs(options.sourceRoot)
+	if err != nil {
+		return nil, stats, err
+	}
+	toplevel, err := git(repo, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, stats, err
+	}
+	toRev, err := git(repo, "rev-parse", "--verify", to+"^{commit}")
+	if err != nil {
+		return nil, stats, err
+	}
+
+	diffs := map[string]map[string]*fileDiff{}
+	remapped := make(SearchList, 0, len(list))
+	for _, stmt := range list {
+		copied := *stmt
+		// Statements outside of the repository have no revision, since it is
+		// only recorded for the main module.
+		relPath, err := filepath.Rel(toplevel, filepath.Join(sourceRoot, stmt.SourceFile))
+		if err != nil || strings.HasPrefix(relPath, "..") {
+			stats.NumOutside++
+			remapped = append(remapped, &copied)
+			continue
+		}
+
+		from := options.from
+		if from == "" {
+			from = stmt.Revision
+		}
+		if from == "" {
+			return nil, stats, fmt.Errorf("%s:%d: statement has no revision, the commit the search list was generated at must be given", stmt.SourceFile, stmt.LineNumber)
+		}
+		fileDiffs, ok := diffs[from]
+		if !ok {
+			fileDiffs, err = diffCommits(repo, from, toRev)
+			if err != nil {
+				return nil, stats, err
+			}
+			diffs[from] = fileDiffs
+		}
+		if copied.Revision != "" {
+			copied.Revision = toRev
+		}
+		fd, ok := fileDiffs[filepath.ToSlash(relPath)]
+		if !ok {
+			stats.NumUnchanged++
+			remapped = append(remapped, &copied)
+			continue
+		}
+		line, ok := fd.mapLine(stmt.LineNumber)
+		if !ok || fd.newPath == "" {
+			stats.NumDropped++
+			continue
+		}
+		if fd.newPath != fd.oldPath {
+			newPath := filepath.Join(toplevel, filepath.FromSlash(fd.newPath))
+			if rel, err := filepath.Rel(sourceRoot, newPath); err == nil {
+				copied.SourceFile = rel
+			}
+		}
+		copied.LineNumber = line
+		if copied.LineNumber == stmt.LineNumber && copied.SourceFile == stmt.SourceFile {
+			stats.NumUnchanged++
+		} else {
+			stats.NumMoved++
+		}
+		remapped = append(remapped, &copied)
+	}
+	return remapped, stats, nil
+}
+
+func git(repo string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// fileDiff contains the hunks of a file changed between two commits. Paths
+// are relative to the repository root. newPath is empty if the file was
+// deleted.
+type fileDiff struct {
+	oldPath, newPath string
+	hunks            []hunk
+}
+
+// hunk is a change of oldCount lines at oldStart into newCount lines at
+// newStart. If oldCount is 0, the lines were inserted after line oldStart.
+type hunk struct {
+	oldStart, oldCount int
+	newStart, newCount int
+}
+
+// mapLine returns the line number a line of the old file has in the new file,
+// or false if the line was deleted or modified.
+func (fd *fileDiff) mapLine(line int) (int, bool) {
+	offset := 0
+	for _, h := range fd.hunks {
+		if h.oldCount == 0 {
+			if h.oldStart >= line {
+				break
+			}
+		} else {
+			if h.oldStart > line {
+				break
+			}
+			if line < h.oldStart+h.oldCount {
+				return 0, false
+			}
+		}
+		offset += h.newCount - h.oldCount
+	}
+	return line + offset, true
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// diffCommits returns the changed files between two commits, by old path.
+func diffCommits(repo, from, to string) (map[string]*fileDiff, error) {
+	// explicit prefixes, since diff.noprefix and diff.mnemonicPrefix change
+	// the ones parseDiff expects
+	out, err := git(repo, "diff", "-U0", "--no-color", "--no-ext-diff", "-M",
+		"--src-prefix=a/", "--dst-prefix=b/", from, to, "--")
+	if err != nil {
+		return nil, err
+	}
+	return parseDiff(out)
+}
+
+// parseDiff parses the output of git diff -U0.
+func parseDiff(diff string) (map[string]*fileDiff, error) {
+	files := map[string]*fileDiff{}
+	var current *fileDiff
+	// the number of lines of the current hunk not read yet, which may look
+	// like headers, e.g. a removed line "-- comment" is "--- comment"
+	var oldLeft, newLeft int
+	scanner := bufio.NewScanner(strings.NewReader(diff))
+	scanner.Buffer(nil, 1<<24)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(line, "-"):
+				oldLeft--
+			case strings.HasPrefix(line, "+"):
+				newLeft--
+			case strings.HasPrefix(line, " "):
+				oldLeft--
+				newLeft--
+			}
+			// "\ No newline at end of file" does not count
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			current = &fileDiff{}
+		case current == nil:
+		case strings.HasPrefix(line, "--- "):
+			current.oldPath = diffPath(line[4:], "a/")
+		case strings.HasPrefix(line, "+++ "):
+			current.newPath = diffPath(line[4:], "b/")
+			if current.oldPath != "" {
+				files[current.oldPath] = current
+			}
+		case strings.HasPrefix(line, "rename from "):
+			current.oldPath = unquotePath(line[len("rename from "):])
+		case strings.HasPrefix(line, "rename to "):
+			// Renames without changes have no ---/+++ lines.
+			current.newPath = unquotePath(line[len("rename to "):])
+			files[current.oldPath] = current
+		case strings.HasPrefix(line, "@@ "):
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("malformed hunk header: %s", line)
+			}
+			h := hunk{
+				oldStart: atoiDefault(m[1], 0),
+				oldCount: atoiDefault(m[2], 1),
+				newStart: atoiDefault(m[3], 0),
+				newCount: atoiDefault(m[4], 1),
+			}
+			current.hunks = append(current.hunks, h)
+			oldLeft, newLeft = h.oldCount, h.newCount
+		}
+	}
+	return files, scanner.Err()
+}
+
+// diffPath returns the path in a ---/+++ line, or an empty string for
+// /dev/null.
+func diffPath(s, prefix string) string {
+	s = unquotePath(strings.TrimSuffix(s, "\t"))
+	if s == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(s, prefix)
+}
+
+func unquotePath(s string) string {
+	if strings.HasPrefix(s, `"`) {
+		if unquoted, err := strconv.Unquote(s); err == nil {
+			return unquoted
+		}
+	}
+	return s
+}
+
+func atoiDefault(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return n
+}
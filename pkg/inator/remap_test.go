@@ -0,0 +1,112 @@
+package inator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseDiff(t *testing.T) {
+	diff := `diff --git a/pkg/a.go b/pkg/a.go
+index 1111111..2222222 100644
+--- a/pkg/a.go
++++ b/pkg/a.go
+@@ -0,0 +1,2 @@
++// two new lines
++
+@@ -10 +12 @@ func F() {
+-	klog.Info("old")
++	klog.Info("new")
+@@ -20,3 +21,0 @@ func G() {
+-	a()
+-	b()
+-	c()
+@@ -30,2 +29,2 @@ const query = ` + "`" + `
+--- removed comment
+-++ removed line
++++ b/garbage.go
++--- a/garbage.go
+diff --git a/pkg/old.go b/pkg/new.go
+similarity index 100%
+rename from pkg/old.go
+rename to pkg/new.go
+diff --git a/pkg/gone.go b/pkg/gone.go
+deleted file mode 100644
+index 3333333..0000000
+--- a/pkg/gone.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package pkg
+-
+`
+	files, err := parseDiff(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := files["pkg/a.go"]
+	if a == nil || len(a.hunks) != 4 || a.newPath != "pkg/a.go" {
+		t.Fatalf("unexpected diff for pkg/a.go: %+v", a)
+	}
+	cases := map[int]struct {
+		line int
+		ok   bool
+	}{
+		1:  {3, true},
+		9:  {11, true},
+		10: {0, false},
+		11: {13, true},
+		20: {0, false},
+		22: {0, false},
+		23: {22, true},
+		31: {0, false},
+		32: {31, true},
+	}
+	for old, expected := range cases {
+		line, ok := a.mapLine(old)
+		if line != expected.line || ok != expected.ok {
+			t.Errorf("line %d: expected (%d, %v), got (%d, %v)", old, expected.line, expected.ok, line, ok)
+		}
+	}
+	if renamed := files["pkg/old.go"]; renamed == nil || renamed.newPath != "pkg/new.go" {
+		t.Errorf("unexpected diff for pkg/old.go: %+v", renamed)
+	}
+	if gone := files["pkg/gone.go"]; gone == nil || gone.newPath != "" {
+		t.Errorf("unexpected diff for pkg/gone.go: %+v", gone)
+	}
+}
+
+func TestRemapWithoutRevision(t *testing.T) {
+	repo := t.TempDir()
+	run := func(args ...string) string {
+		out, err := git(repo, append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	run("init", "-q")
+	if err := os.WriteFile(filepath.Join(repo, "a.go"), []byte("package a\n\nfunc f() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run("add", "a.go")
+	run("commit", "-q", "-m", "a")
+	rev := run("rev-parse", "HEAD")
+
+	inRepo := &LogStatement{SourceFile: "a.go", LineNumber: 3, Revision: rev}
+	dependency := &LogStatement{SourceFile: "../go/pkg/mod/k8s.io/klog/v2@v2.80.1/klog.go", LineNumber: 10}
+	remapped, stats, err := RemapSearchList(SearchList{inRepo, dependency}, repo, "HEAD", WithSourceRoot(repo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remapped) != 2 || stats.NumOutside != 1 || stats.NumUnchanged != 1 {
+		t.Errorf("got %d statements and %+v, want 2 statements, 1 outside and 1 unchanged", len(remapped), stats)
+	}
+
+	noRevision := &LogStatement{SourceFile: "a.go", LineNumber: 3}
+	if _, _, err := RemapSearchList(SearchList{noRevision}, repo, "HEAD", WithSourceRoot(repo)); err == nil {
+		t.Error("expected an error for a statement in the repository without a revision")
+	}
+	if _, _, err := RemapSearchList(SearchList{noRevision}, repo, "HEAD", WithSourceRoot(repo), WithFromRevision(rev)); err != nil {
+		t.Errorf("unexpected error with the revision given: %v", err)
+	}
+}
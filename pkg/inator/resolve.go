@@ -0,0 +1,668 @@
+package inator
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+const (
+	klogImportPath = "k8s.io/klog/v2"
+	logrImportPath = "github.com/go-logr/logr"
+)
+
+// klogImportPaths contains klog and the libraries it is derived from, which
+// all have the same API (or a subset of it) and write the same log header.
+var klogImportPaths = []string{
+	klogImportPath,
+	"k8s.io/klog",
+	"github.com/golang/glog",
+}
+
+// unvendor strips the vendor directory from an import path, as in
+// k8s.io/kubernetes/vendor/k8s.io/klog/v2.
+func unvendor(path string) string {
+	if i := strings.LastIndex(path, "/vendor/"); i >= 0 {
+		return path[i+len("/vendor/"):]
+	}
+	return strings.TrimPrefix(path, "vendor/")
+}
+
+func isKlogImportPath(path string) bool {
+	path = unvendor(path)
+	for _, p := range klogImportPaths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+func isLogrImportPath(path string) bool {
+	return unvendor(path) == logrImportPath
+}
+
+// klogLoggerFuncs are the klog functions which return a logr.Logger.
+var klogLoggerFuncs = map[string]bool{
+	"Background":       true,
+	"TODO":             true,
+	"FromContext":      true,
+	"LoggerWithValues": true,
+	"LoggerWithName":   true,
+	"NewKlogr":         true,
+}
+
+// logCall describes a call expression which was resolved to one of the
+// logging functions in the registry.
+type logCall struct {
+	meta klogFunctionMeta
+	// The arguments passed to V(), if the function was called on a
+	// klog.Verbose value or a logr.Logger and the corresponding V() calls could
+	// be found. logr V-levels are additive, so there may be several.
+	verbosity []ast.Expr
+	// True if the call is a method call on a logr.Logger.
+	contextual bool
+}
+
+// A callResolver decides whether a call expression is a call to one of the
+// logging functions in the registry.
+type callResolver interface {
+	resolve(call *ast.CallExpr) (logCall, bool)
+	// constValue returns the value of a constant expression, or nil if the
+	// expression is not constant or its value cannot be determined.
+	constValue(expr ast.Expr) constant.Value
+	// calleeKey returns the import path and name of the package-level
+	// function (or function-typed variable) called by the call expression,
+	// or the key of the method (see wrapperKey) if it is known, or an empty
+	// string otherwise. Method calls are only resolved with type information.
+	calleeKey(call *ast.CallExpr) string
+	// typeName returns the type of the expression as an import path and type
+	// name, such as *net/http.Request, or an empty string if it is not known.
+	typeName(expr ast.Expr) string
+	// structFields returns the field names of the struct type (or pointer to
+	// struct type) of the expression, or nil if it is not a struct or its type
+	// is not known.
+	structFields(expr ast.Expr) []string
+}
+
+// syntacticResolver matches calls by comparing identifier names with the
+// names under which the packages in the registry were imported in the file.
+// It only recognizes the forms klog.FunctionName(...) and
+// klog.V(n).FunctionName(...) (or FunctionName(...) and V(n).FunctionName(...)
+// if the package is dot-imported), and calls on loggers obtained from klog in
+// the same function, such as klog.FromContext(ctx).V(n).Info(...) or
+// `logger := klog.Background()`.
+type syntacticResolver struct {
+	registry *registry
+	// import names of the packages in the registry mapped to import paths
+	logPackages map[string]string
+	// import paths of the dot-imported packages in the registry
+	dotImports []string
+	pkgPath    string
+	scope      *ast.Scope
+	// import names mapped to import paths
+	imports map[string]string
+	// constants declared in all files of the package
+	consts packageConsts
+}
+
+func newSyntacticResolver(f *ast.File, pkgPath string, reg *registry, consts packageConsts) *syntacticResolver {
+	r := &syntacticResolver{
+		registry:    reg,
+		logPackages: map[string]string{},
+		pkgPath:     pkgPath,
+		scope:       f.Scope,
+		imports:     map[string]string{},
+		consts:      consts,
+	}
+	for _, im := range f.Imports {
+		path, err := strconv.Unquote(im.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := guessPackageName(path)
+		if im.Name != nil {
+			name = im.Name.Name
+		}
+		r.imports[name] = path
+		if reg.hasPackage(path) {
+			switch name {
+			case ".":
+				r.dotImports = append(r.dotImports, path)
+			case "_":
+			default:
+				r.logPackages[name] = path
+			}
+		}
+	}
+	return r
+}
+
+// guessPackageName returns the package name for an import path without
+// loading the package, using the last path element that is not a major
+// version suffix.
+func guessPackageName(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2]
+	}
+	return strings.TrimPrefix(name, "go-")
+}
+
+func (r *syntacticResolver) resolve(call *ast.CallExpr) (logCall, bool) {
+	// Try to match one of the two possible formats:
+	// 1. klog.FunctionName(...)
+	// 2. klog.V(...).FunctionName(...)
+	//
+	// Below, Fun is a klog function in the first case, or a SelectorExpr
+	// whose X is a call to klog.V (or any other chain of calls returning a
+	// value with logging methods) in the second case:
+	// 1. klog.FunctionName(...)
+	//    ^^^^^^^^^^^^^^^^^
+	// 2. klog.V(...).FunctionName(...)
+	//    ^^^^^^^^^^^
+	if path, name, ok := r.packageFunc(call.Fun); ok {
+		meta, ok := r.registry.lookup(path, "", name)
+		return logCall{meta: meta}, ok
+	}
+	fun, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return logCall{}, false
+	}
+	recv, ok := r.loggerValue(fun.X, 0)
+	if !ok {
+		return logCall{}, false
+	}
+	meta, ok := r.registry.lookup(recv.path, recv.typeName, fun.Sel.Name)
+	if !ok {
+		return logCall{}, false
+	}
+	return logCall{
+		meta:       meta,
+		verbosity:  recv.levels,
+		contextual: isLogrImportPath(recv.path),
+	}, true
+}
+
+// packageFunc returns the import path and name of the function if expr
+// refers to a function in one of the packages in the registry, i.e. it is a
+// selector on the name of one of the packages or, if the package is
+// dot-imported, an identifier which is not declared in the file.
+func (r *syntacticResolver) packageFunc(expr ast.Expr) (path, name string, ok bool) {
+	switch e := expr.(type) {
+	case *ast.SelectorExpr:
+		if id, ok := e.X.(*ast.Ident); ok && id.Obj == nil {
+			if path, ok := r.logPackages[id.Name]; ok {
+				return path, e.Sel.Name, true
+			}
+		}
+	case *ast.Ident:
+		if e.Obj != nil {
+			break
+		}
+		for _, path := range r.dotImports {
+			if r.registry.declares(path, e.Name) || (isKlogImportPath(path) && klogLoggerFuncs[e.Name]) {
+				return path, e.Name, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+func (r *syntacticResolver) constValue(expr ast.Expr) constant.Value {
+	return r.consts.fold(expr, 0)
+}
+
+// typeName only knows the types of variables declared with an explicit type
+// or initialized with a composite literal in the same file, such as function
+// parameters.
+func (r *syntacticResolver) typeName(expr ast.Expr) string {
+	var name func(t ast.Expr) string
+	name = func(t ast.Expr) string {
+		switch t := t.(type) {
+		case *ast.StarExpr:
+			if n := name(t.X); n != "" {
+				return "*" + n
+			}
+		case *ast.Ident:
+			if r.scope.Lookup(t.Name) != nil {
+				return r.pkgPath + "." + t.Name
+			}
+		case *ast.SelectorExpr:
+			if id, ok := t.X.(*ast.Ident); ok {
+				if path, ok := r.imports[id.Name]; ok {
+					return path + "." + t.Sel.Name
+				}
+			}
+		}
+		return ""
+	}
+	return name(r.declaredType(expr, 0))
+}
+
+// structFields only knows the struct types declared in the same file.
+func (r *syntacticResolver) structFields(expr ast.Expr) []string {
+	t := r.declaredType(expr, 0)
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	id, ok := t.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	obj := r.scope.Lookup(id.Name)
+	if obj == nil {
+		return nil
+	}
+	spec, ok := obj.Decl.(*ast.TypeSpec)
+	if !ok {
+		return nil
+	}
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return nil
+	}
+	var fields []string
+	for _, field := range st.Fields.List {
+		for _, name := range field.Names {
+			fields = append(fields, name.Name)
+		}
+	}
+	return fields
+}
+
+// declaredType returns the type expression of a variable declared with an
+// explicit type or initialized with a (pointer to a) composite literal, or of
+// a composite literal.
+func (r *syntacticResolver) declaredType(expr ast.Expr, depth int) ast.Expr {
+	if depth > 16 {
+		return nil
+	}
+	switch e := expr.(type) {
+	case *ast.ParenExpr:
+		return r.declaredType(e.X, depth+1)
+	case *ast.CompositeLit:
+		return e.Type
+	case *ast.UnaryExpr:
+		if e.Op == token.AND {
+			if t := r.declaredType(e.X, depth+1); t != nil {
+				return &ast.StarExpr{X: t}
+			}
+		}
+	case *ast.Ident:
+		if e.Obj == nil {
+			return nil
+		}
+		switch decl := e.Obj.Decl.(type) {
+		case *ast.Field:
+			return decl.Type
+		case *ast.ValueSpec:
+			if decl.Type != nil {
+				return decl.Type
+			}
+			for i, name := range decl.Names {
+				if name.Name == e.Name && len(decl.Values) == len(decl.Names) {
+					return r.declaredType(decl.Values[i], depth+1)
+				}
+			}
+		case *ast.AssignStmt:
+			for i, lhs := range decl.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok && id.Name == e.Name && len(decl.Rhs) == len(decl.Lhs) {
+					return r.declaredType(decl.Rhs[i], depth+1)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (r *syntacticResolver) calleeKey(call *ast.CallExpr) string {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		// Identifiers declared at package level in another file of the
+		// package are not resolved by the parser.
+		if fun.Obj == nil || r.scope.Lookup(fun.Name) == fun.Obj {
+			return r.pkgPath + "." + fun.Name
+		}
+	case *ast.SelectorExpr:
+		if id, ok := fun.X.(*ast.Ident); ok && id.Obj == nil {
+			if path, ok := r.imports[id.Name]; ok {
+				return path + "." + fun.Sel.Name
+			}
+		}
+	}
+	return ""
+}
+
+// loggerValue describes a value with logging methods, such as a klog.Verbose
+// or a logr.Logger, and the arguments of the V() calls it was obtained with.
+type loggerValue struct {
+	path     string
+	typeName string
+	levels   []ast.Expr
+}
+
+// loggerValue checks whether the expression is a value returned by one of the
+// verbosity sources in the registry (such as klog.V) or by one of the
+// functions in klogLoggerFuncs, either directly or through a variable and a
+// chain of method calls such as WithName("x").V(2).
+func (r *syntacticResolver) loggerValue(expr ast.Expr, depth int) (loggerValue, bool) {
+	if depth > 16 {
+		return loggerValue{}, false
+	}
+	depth++
+	switch e := expr.(type) {
+	case *ast.ParenExpr:
+		return r.loggerValue(e.X, depth)
+	case *ast.Ident:
+		value := identValue(e)
+		if value == nil {
+			return loggerValue{}, false
+		}
+		return r.loggerValue(value, depth)
+	case *ast.CallExpr:
+		if path, name, ok := r.packageFunc(e.Fun); ok {
+			if src, ok := r.registry.verbositySource(path, "", name); ok && len(e.Args) > src.arg {
+				return loggerValue{path: path, typeName: src.returns, levels: []ast.Expr{e.Args[src.arg]}}, true
+			}
+			if isKlogImportPath(path) && klogLoggerFuncs[name] {
+				return loggerValue{path: logrImportPath, typeName: "Logger"}, true
+			}
+			return loggerValue{}, false
+		}
+		sel, ok := e.Fun.(*ast.SelectorExpr)
+		if !ok {
+			return loggerValue{}, false
+		}
+		v, ok := r.loggerValue(sel.X, depth)
+		if !ok {
+			return loggerValue{}, false
+		}
+		if src, ok := r.registry.verbositySource(v.path, v.typeName, sel.Sel.Name); ok && len(e.Args) > src.arg {
+			v.levels = append([]ast.Expr{e.Args[src.arg]}, v.levels...)
+			v.typeName = src.returns
+			return v, true
+		}
+		if isLogrImportPath(v.path) && v.typeName == "Logger" {
+			switch sel.Sel.Name {
+			case "WithValues", "WithName", "WithCallDepth":
+				return v, true
+			}
+		}
+	}
+	return loggerValue{}, false
+}
+
+// identValue returns the expression a local variable was initialized with,
+// using the object resolution done by the parser.
+func identValue(id *ast.Ident) ast.Expr {
+	if id.Obj == nil || id.Obj.Kind != ast.Var {
+		return nil
+	}
+	var lhs, rhs []ast.Expr
+	switch decl := id.Obj.Decl.(type) {
+	case *ast.AssignStmt:
+		lhs, rhs = decl.Lhs, decl.Rhs
+	case *ast.ValueSpec:
+		for _, name := range decl.Names {
+			lhs = append(lhs, name)
+		}
+		rhs = decl.Values
+	}
+	if len(lhs) != len(rhs) {
+		return nil
+	}
+	for i, expr := range lhs {
+		if name, ok := expr.(*ast.Ident); ok && name.Name == id.Name {
+			return rhs[i]
+		}
+	}
+	return nil
+}
+
+// typedResolver resolves the callee of every call using type information,
+// so it finds logging calls regardless of how the function or klog.Verbose
+// value was obtained, and ignores unrelated identifiers which happen to be
+// named klog.
+type typedResolver struct {
+	info     *types.Info
+	registry *registry
+	// Variables in the file which are assigned exactly once, mapped to the
+	// expression they were assigned. This is used to follow variables such as
+	// v in `v := klog.V(2); v.Info(...)` back to the V() call.
+	values map[types.Object]ast.Expr
+}
+
+func newTypedResolver(info *types.Info, f *ast.File, reg *registry) *typedResolver {
+	r := &typedResolver{
+		info:     info,
+		registry: reg,
+		values:   map[types.Object]ast.Expr{},
+	}
+	assignments := map[types.Object]int{}
+	record := func(lhs []ast.Expr, rhs []ast.Expr) {
+		for i, expr := range lhs {
+			id, ok := expr.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			obj := info.ObjectOf(id)
+			if obj == nil {
+				continue
+			}
+			assignments[obj]++
+			if len(lhs) == len(rhs) {
+				r.values[obj] = rhs[i]
+			}
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			record(n.Lhs, n.Rhs)
+		case *ast.ValueSpec:
+			lhs := make([]ast.Expr, len(n.Names))
+			for i, name := range n.Names {
+				lhs[i] = name
+			}
+			record(lhs, n.Values)
+		}
+		return true
+	})
+	for obj, count := range assignments {
+		if count > 1 {
+			delete(r.values, obj)
+		}
+	}
+	return r
+}
+
+// unwrap strips parentheses and follows variables to the expression they
+// were assigned, if it is known.
+func (r *typedResolver) unwrap(expr ast.Expr) ast.Expr {
+	for i := 0; i < 16; i++ {
+		switch e := expr.(type) {
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.Ident:
+			value, ok := r.values[r.info.ObjectOf(e)]
+			if !ok {
+				return expr
+			}
+			expr = value
+		default:
+			return expr
+		}
+	}
+	return expr
+}
+
+// callee returns the function or method called by the given call expression,
+// or nil if it is not statically known. If the function was selected from a
+// package or value, the selector expression is returned as well.
+func (r *typedResolver) callee(call *ast.CallExpr) (*types.Func, *ast.SelectorExpr) {
+	var id *ast.Ident
+	var sel *ast.SelectorExpr
+	switch fun := r.unwrap(call.Fun).(type) {
+	case *ast.SelectorExpr:
+		id, sel = fun.Sel, fun
+	case *ast.Ident:
+		id = fun
+	default:
+		return nil, nil
+	}
+	fn, ok := r.info.Uses[id].(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return nil, nil
+	}
+	return fn, sel
+}
+
+func (r *typedResolver) resolve(call *ast.CallExpr) (logCall, bool) {
+	fn, sel := r.callee(call)
+	if fn == nil {
+		return logCall{}, false
+	}
+	path := fn.Pkg().Path()
+	recv := receiverName(fn)
+	meta, ok := r.registry.lookup(path, recv, fn.Name())
+	if !ok {
+		return logCall{}, false
+	}
+	lc := logCall{meta: meta, contextual: isLogrImportPath(path)}
+	if recv != "" && sel != nil {
+		lc.verbosity = r.verbosityLevels(sel.X)
+		if len(lc.verbosity) == 0 && r.registry.isGated(path, recv) {
+			// The level is not known, e.g. for a klog.Verbose parameter.
+			// The receiver is not constant, so the statement will be
+			// recorded with an unknown verbosity.
+			lc.verbosity = []ast.Expr{sel.X}
+		}
+	}
+	return lc, true
+}
+
+func (r *typedResolver) constValue(expr ast.Expr) constant.Value {
+	if tv, ok := r.info.Types[expr]; ok && tv.Value != nil {
+		return tv.Value
+	}
+	return nil
+}
+
+func (r *typedResolver) typeName(expr ast.Expr) string {
+	t := r.info.TypeOf(expr)
+	if t == nil {
+		return ""
+	}
+	return types.TypeString(t, nil)
+}
+
+func (r *typedResolver) structFields(expr ast.Expr) []string {
+	t := r.info.TypeOf(expr)
+	if t == nil {
+		return nil
+	}
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+	fields := make([]string, st.NumFields())
+	for i := range fields {
+		fields[i] = st.Field(i).Name()
+	}
+	return fields
+}
+
+func (r *typedResolver) calleeKey(call *ast.CallExpr) string {
+	var id *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return ""
+	}
+	obj := r.info.Uses[id]
+	if obj == nil || obj.Pkg() == nil {
+		return ""
+	}
+	switch obj := obj.(type) {
+	case *types.Func:
+		if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+			return methodKey(recv.Type(), obj)
+		}
+	case *types.Var:
+		if obj.Parent() != obj.Pkg().Scope() {
+			return ""
+		}
+	default:
+		return ""
+	}
+	return obj.Pkg().Path() + "." + obj.Name()
+}
+
+// methodKey returns the key of a method (see wrapperKey) with the given
+// receiver type, or an empty string for interface methods, which cannot be
+// resolved to a declaration.
+func methodKey(recv types.Type, method *types.Func) string {
+	ptr := ""
+	if p, ok := recv.(*types.Pointer); ok {
+		ptr, recv = "*", p.Elem()
+	}
+	named, ok := recv.(*types.Named)
+	if !ok || types.IsInterface(named) {
+		return ""
+	}
+	return wrapperKey(method.Pkg().Path(), method.Name(), ptr+named.Obj().Name())
+}
+
+// verbosityLevels follows a chain of calls such as klog.V(2) or
+// logger.WithName("x").V(2).V(1) and returns the V-level arguments of every
+// verbosity source in it.
+func (r *typedResolver) verbosityLevels(expr ast.Expr) []ast.Expr {
+	var levels []ast.Expr
+	for i := 0; i < 16; i++ {
+		call, ok := r.unwrap(expr).(*ast.CallExpr)
+		if !ok {
+			return levels
+		}
+		fn, sel := r.callee(call)
+		if fn == nil || !r.registry.hasPackage(fn.Pkg().Path()) {
+			return levels
+		}
+		recv := receiverName(fn)
+		if src, ok := r.registry.verbositySource(fn.Pkg().Path(), recv, fn.Name()); ok && len(call.Args) > src.arg {
+			levels = append(levels, call.Args[src.arg])
+		}
+		if recv == "" || sel == nil {
+			return levels
+		}
+		expr = sel.X
+	}
+	return levels
+}
+
+// receiverName returns the name of the receiver type of a method, or an
+// empty string if fn is not a method.
+func receiverName(fn *types.Func) string {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return ""
+	}
+	t := recv.Type()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Obj().Name()
+	}
+	return ""
+}
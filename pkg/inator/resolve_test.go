@@ -0,0 +1,35 @@
+package inator
+
+import "testing"
+
+func TestIsKlogImportPath(t *testing.T) {
+	cases := map[string]bool{
+		"k8s.io/klog/v2":                          true,
+		"k8s.io/klog":                             true,
+		"github.com/golang/glog":                  true,
+		"k8s.io/kubernetes/vendor/k8s.io/klog/v2": true,
+		"vendor/github.com/golang/glog":           true,
+		"k8s.io/klog/v2/klogr":                    false,
+		"example.com/klog":                        false,
+		"k8s.io/kubernetes/vendor/example.com/x":  false,
+	}
+	for path, expected := range cases {
+		if actual := isKlogImportPath(path); actual != expected {
+			t.Errorf("%s: expected %v, got %v", path, expected, actual)
+		}
+	}
+}
+
+func TestGuessPackageName(t *testing.T) {
+	cases := map[string]string{
+		"k8s.io/klog/v2":          "klog",
+		"github.com/go-logr/logr": "logr",
+		"example.com/util":        "util",
+		"v2":                      "v2",
+	}
+	for path, expected := range cases {
+		if actual := guessPackageName(path); actual != expected {
+			t.Errorf("%s: expected %s, got %s", path, expected, actual)
+		}
+	}
+}
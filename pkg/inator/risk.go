@@ -0,0 +1,331 @@
+package inator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// RiskLevel is how likely a risk is to leak sensitive data into the logs.
+type RiskLevel string
+
+const (
+	RiskLow    RiskLevel = "low"
+	RiskMedium RiskLevel = "medium"
+	RiskHigh   RiskLevel = "high"
+)
+
+func (l RiskLevel) rank() int {
+	switch l {
+	case RiskLow:
+		return 1
+	case RiskMedium:
+		return 2
+	case RiskHigh:
+		return 3
+	}
+	return 0
+}
+
+// ParseRiskLevel parses a risk level name (low, medium or high).
+func ParseRiskLevel(s string) (RiskLevel, error) {
+	l := RiskLevel(strings.ToLower(s))
+	if l.rank() == 0 {
+		return "", fmt.Errorf("unknown risk level %q", s)
+	}
+	return l, nil
+}
+
+// RiskKind identifies why an argument of a log statement may contain
+// sensitive data.
+type RiskKind string
+
+const (
+	// The argument, its field, or the key it is logged under is named like a
+	// secret, e.g. password or apiKey.
+	RiskSensitiveName RiskKind = "sensitive-name"
+	// The argument is a whole *http.Request, including its headers.
+	RiskHTTPRequest RiskKind = "http-request"
+	// The argument is a Kubernetes Secret object.
+	RiskSecretObject RiskKind = "secret-object"
+	// The argument is formatted with %+v or %#v, and is a struct with a
+	// field named like a secret.
+	RiskStructDump RiskKind = "struct-dump"
+)
+
+// Risk is an argument of a log statement which may contain sensitive data.
+type Risk struct {
+	Kind  RiskKind  `json:"kind"`
+	Level RiskLevel `json:"level"`
+	// Argument is the source text of the argument.
+	Argument string `json:"argument"`
+	Reason   string `json:"reason"`
+}
+
+func (r Risk) String() string {
+	return fmt.Sprintf("[%s] %s: %s (%s)", r.Level, r.Argument, r.Reason, r.Kind)
+}
+
+// RiskLevel returns the highest level of the risks of the statement, or an
+// empty string if it has none.
+func (stmt *LogStatement) RiskLevel() RiskLevel {
+	var level RiskLevel
+	for _, risk := range stmt.Risks {
+		if risk.Level.rank() > level.rank() {
+			level = risk.Level
+		}
+	}
+	return level
+}
+
+// Risky returns the statements with at least one risk of the given level or
+// higher.
+func (sl SearchList) Risky(min RiskLevel) SearchList {
+	var risky SearchList
+	for _, stmt := range sl {
+		if level := stmt.RiskLevel(); level != "" && level.rank() >= min.rank() {
+			risky = append(risky, stmt)
+		}
+	}
+	return risky
+}
+
+// sensitiveWords are the words of identifiers which suggest a secret,
+// mapped to the risk level of logging them. "key" on its own is usually a
+// cache or map key, so it is only a high risk in compounds like apiKey.
+var sensitiveWords = map[string]RiskLevel{
+	"token":      RiskHigh,
+	"password":   RiskHigh,
+	"passwd":     RiskHigh,
+	"secret":     RiskHigh,
+	"credential": RiskHigh,
+	"key":        RiskLow,
+}
+
+var sensitiveKeyQualifiers = map[string]bool{
+	"private":    true,
+	"api":        true,
+	"access":     true,
+	"signing":    true,
+	"encryption": true,
+	"session":    true,
+}
+
+// sensitiveName returns the risk level of logging a value with the given
+// name (an identifier, field name or structured logging key), or an empty
+// string if the name does not suggest a secret. The name is split into words
+// at underscores, dashes and case changes, so that tokenizer does not match
+// but userToken and bearer_token do.
+func sensitiveName(name string) RiskLevel {
+	words := splitWords(name)
+	var level RiskLevel
+	for i, word := range words {
+		l, ok := sensitiveWords[strings.TrimSuffix(word, "s")]
+		if !ok {
+			l, ok = sensitiveWords[word]
+		}
+		if !ok {
+			continue
+		}
+		if l == RiskLow && i > 0 && sensitiveKeyQualifiers[words[i-1]] {
+			l = RiskHigh
+		}
+		if l.rank() > level.rank() {
+			level = l
+		}
+	}
+	return level
+}
+
+// splitWords splits an identifier into lower case words.
+func splitWords(name string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(name)
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	for i, c := range runes {
+		switch {
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			flush()
+			continue
+		case unicode.IsUpper(c) && len(word) > 0:
+			// a new word starts at an upper case letter following a lower
+			// case one, or at the last upper case letter of an acronym
+			// followed by a lower case one (APIKey -> api, key)
+			prev := runes[i-1]
+			if unicode.IsLower(prev) || (i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				flush()
+			}
+		}
+		word = append(word, c)
+	}
+	flush()
+	return words
+}
+
+// argumentName returns the name under which an argument is known, i.e. the
+// identifier or selected field, ignoring parentheses, dereferences and
+// indexing.
+func argumentName(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.Ident:
+			return e.Name
+		case *ast.SelectorExpr:
+			return e.Sel.Name
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.UnaryExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		default:
+			return ""
+		}
+	}
+}
+
+// secretTypes are the types of objects which contain secrets as a whole.
+var secretTypes = map[string]RiskKind{
+	"*net/http.Request":          RiskHTTPRequest,
+	"net/http.Request":           RiskHTTPRequest,
+	"*k8s.io/api/core/v1.Secret": RiskSecretObject,
+	"k8s.io/api/core/v1.Secret":  RiskSecretObject,
+}
+
+// assessRisks checks the arguments of a log call for values which may
+// contain sensitive data. The message (or format string) itself is only
+// checked if it is not constant and the function concatenates its arguments,
+// as in klog.Info(password).
+func assessRisks(call *ast.CallExpr, meta klogFunctionMeta, stmt *LogStatement, resolver callResolver) []Risk {
+	if len(call.Args) <= meta.FormatStringPos {
+		return nil
+	}
+	args := call.Args[meta.FormatStringPos+1:]
+	if stmt.DynamicFormat && (meta.Message == MessagePrint || meta.Message == MessagePrintln) {
+		args = call.Args[meta.FormatStringPos:]
+	}
+
+	// the verbs used to format each argument of printf-style calls
+	var verbs []string
+	if meta.Message == MessagePrintf && !stmt.DynamicFormat {
+		if format, err := strconv.Unquote(stmt.FormatString); err == nil {
+			verbs = argumentVerbs(format)
+		}
+	}
+
+	var risks []Risk
+	add := func(arg ast.Expr, risk Risk) {
+		risk.Argument = types.ExprString(arg)
+		// report each argument once, at its highest level
+		for i := range risks {
+			if risks[i].Argument == risk.Argument {
+				if risk.Level.rank() > risks[i].Level.rank() {
+					risks[i] = risk
+				}
+				return
+			}
+		}
+		risks = append(risks, risk)
+	}
+	for i, arg := range args {
+		if meta.Message == MessageStructured && i%2 == 0 {
+			// a key, which is checked along with its value
+			continue
+		}
+		if resolver.constValue(arg) != nil {
+			continue
+		}
+		typeName := resolver.typeName(arg)
+		switch secretTypes[typeName] {
+		case RiskHTTPRequest:
+			add(arg, Risk{
+				Kind:   RiskHTTPRequest,
+				Level:  RiskMedium,
+				Reason: "logs a whole HTTP request, including its headers",
+			})
+		case RiskSecretObject:
+			add(arg, Risk{
+				Kind:   RiskSecretObject,
+				Level:  RiskHigh,
+				Reason: "logs a whole Secret object, including its data",
+			})
+		}
+		if level := sensitiveName(argumentName(arg)); level != "" {
+			add(arg, Risk{
+				Kind:   RiskSensitiveName,
+				Level:  level,
+				Reason: fmt.Sprintf("%s is named like a secret", argumentName(arg)),
+			})
+		}
+		if meta.Message == MessageStructured && i > 0 && !isObjectRef(arg) {
+			if key := resolver.constValue(args[i-1]); key != nil && key.Kind() == constant.String {
+				key := constant.StringVal(key)
+				if level := sensitiveName(key); level != "" {
+					add(arg, Risk{
+						Kind:   RiskSensitiveName,
+						Level:  level,
+						Reason: fmt.Sprintf("logged under the key %q", key),
+					})
+				}
+			}
+		}
+		if i < len(verbs) && (strings.HasSuffix(verbs[i], "+v") || strings.HasSuffix(verbs[i], "#v")) {
+			for _, field := range resolver.structFields(arg) {
+				if level := sensitiveName(field); level != "" {
+					add(arg, Risk{
+						Kind:   RiskStructDump,
+						Level:  level,
+						Reason: fmt.Sprintf("%s prints the field %s", verbs[i], field),
+					})
+				}
+			}
+		}
+	}
+	return risks
+}
+
+// isObjectRef returns true if the expression is a call to klog.KObj or
+// klog.KRef, which only log the namespace and name of an object.
+func isObjectRef(expr ast.Expr) bool {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	switch argumentName(call.Fun) {
+	case "KObj", "KRef", "KObjs", "KObjSlice":
+		return true
+	}
+	return false
+}
+
+// argumentVerbs returns the verb used to format each argument of a format
+// string, or nil if it uses explicit argument indexes. Arguments read by *
+// are assigned an empty verb.
+func argumentVerbs(format string) []string {
+	var verbs []string
+	for _, piece := range parseFormat(format) {
+		if !piece.verb || len(piece.text) == 1 {
+			continue
+		}
+		if strings.Contains(piece.text, "[") {
+			return nil
+		}
+		for i := strings.Count(piece.text, "*"); i > 0; i-- {
+			verbs = append(verbs, "")
+		}
+		verbs = append(verbs, piece.text)
+	}
+	return verbs
+}
@@ -0,0 +1,91 @@
+package inator
+
+import "testing"
+
+func TestSensitiveName(t *testing.T) {
+	cases := map[string]RiskLevel{
+		"password":     RiskHigh,
+		"userToken":    RiskHigh,
+		"bearer_token": RiskHigh,
+		"Secrets":      RiskHigh,
+		"APIKey":       RiskHigh,
+		"privateKey":   RiskHigh,
+		"key":          RiskLow,
+		"cacheKey":     RiskLow,
+		"tokenizer":    "",
+		"keyboard":     "",
+		"name":         "",
+		"":             "",
+	}
+	for name, expected := range cases {
+		if actual := sensitiveName(name); actual != expected {
+			t.Errorf("%q: got %q, want %q", name, actual, expected)
+		}
+	}
+}
+
+func TestAssessRisks(t *testing.T) {
+	src := `package server
+
+import (
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+type Config struct {
+	Host     string
+	Password string
+}
+
+type Server struct {
+	Host string
+}
+
+func run(req *http.Request, secret *corev1.Secret, cfg Config, s Server, opts *Options) {
+	klog.Infof("connecting with %s", opts.Password)
+	klog.InfoS("connecting", "auth", opts.BearerToken, "host", s.Host)
+	klog.InfoS("connecting", "token", opts.Value)
+	klog.Infof("request %v", req)
+	klog.V(2).InfoS("applying", "secret", klog.KObj(secret), "object", secret)
+	klog.Infof("config %+v", cfg)
+	klog.Infof("config %v, server %+v", cfg, s)
+	klog.Info(opts.Password)
+	klog.Infof("syncing %s", key)
+}
+`
+	stmts := searchSource(t, src)
+
+	expected := map[int][]Risk{
+		20: {{Kind: RiskSensitiveName, Level: RiskHigh, Argument: "opts.Password"}},
+		21: {{Kind: RiskSensitiveName, Level: RiskHigh, Argument: "opts.BearerToken"}},
+		22: {{Kind: RiskSensitiveName, Level: RiskHigh, Argument: "opts.Value"}},
+		23: {{Kind: RiskHTTPRequest, Level: RiskMedium, Argument: "req"}},
+		24: {{Kind: RiskSecretObject, Level: RiskHigh, Argument: "secret"}},
+		25: {{Kind: RiskStructDump, Level: RiskHigh, Argument: "cfg"}},
+		26: nil,
+		27: {{Kind: RiskSensitiveName, Level: RiskHigh, Argument: "opts.Password"}},
+		28: {{Kind: RiskSensitiveName, Level: RiskLow, Argument: "key"}},
+	}
+	if len(stmts) != len(expected) {
+		t.Fatalf("expected %d statements, got %d", len(expected), len(stmts))
+	}
+	for _, stmt := range stmts {
+		want := expected[stmt.LineNumber]
+		if len(stmt.Risks) != len(want) {
+			t.Errorf("line %d: got risks %v, want %v", stmt.LineNumber, stmt.Risks, want)
+			continue
+		}
+		for i, risk := range stmt.Risks {
+			if risk.Kind != want[i].Kind || risk.Level != want[i].Level || risk.Argument != want[i].Argument {
+				t.Errorf("line %d: got risk %s, want %+v", stmt.LineNumber, risk, want[i])
+			}
+		}
+	}
+
+	risky := SearchList(stmts).Risky(RiskMedium)
+	if len(risky) != 7 {
+		t.Errorf("expected 7 statements with a medium or high risk, got %d", len(risky))
+	}
+}
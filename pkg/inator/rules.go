@@ -0,0 +1,192 @@
+package inator
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// SeverityRules reclassify the severity of statements, e.g. to treat Info
+// statements reporting failures as errors. The first rule matching a
+// statement is applied.
+type SeverityRules struct {
+	Rules []SeverityRule `yaml:"rules" json:"rules"`
+}
+
+// SeverityRule changes the severity of statements matching all of its
+// conditions. Conditions which are not set always match.
+type SeverityRule struct {
+	Name string `yaml:"name" json:"name"`
+	// Match is a regular expression matched against the format string (or
+	// message). It never matches statements with a dynamic format string.
+	Match string `yaml:"match,omitempty" json:"match,omitempty"`
+	// Packages are import path patterns, such as k8s.io/kubernetes/pkg/* or
+	// k8s.io/kubernetes/pkg/kubelet/... to include subpackages.
+	Packages []string `yaml:"packages,omitempty" json:"packages,omitempty"`
+	// Files are source file patterns, such as pkg/kubelet/*.go. Patterns
+	// without a slash are matched against the file name only.
+	Files []string `yaml:"files,omitempty" json:"files,omitempty"`
+	// Severities restricts the rule to statements with one of the given
+	// severities.
+	Severities []Severity `yaml:"severities,omitempty" json:"severities,omitempty"`
+	// MinVerbosity and MaxVerbosity restrict the rule to statements logged at
+	// the given V-levels. Statements which are not V-gated are logged at
+	// level 0, and statements with an unknown V-level never match.
+	MinVerbosity *int `yaml:"minVerbosity,omitempty" json:"minVerbosity,omitempty"`
+	MaxVerbosity *int `yaml:"maxVerbosity,omitempty" json:"maxVerbosity,omitempty"`
+	// Severity is the new severity of matching statements. It is required,
+	// since an unset severity would silently demote statements to Info.
+	Severity *Severity `yaml:"severity" json:"severity"`
+
+	match *regexp.Regexp
+}
+
+// LoadSeverityRules reads severity rules from a YAML or JSON file.
+func LoadSeverityRules(filename string) (*SeverityRules, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	rules := &SeverityRules{}
+	if err := yaml.UnmarshalStrict(data, rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if err := rules.compile(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return rules, nil
+}
+
+func (r *SeverityRules) compile() error {
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("rule %d: name must not be empty", i+1)
+		}
+		if rule.Severity == nil {
+			return fmt.Errorf("rule %s: severity must be set", rule.Name)
+		}
+		if rule.Match == "" || rule.match != nil {
+			continue
+		}
+		match, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+		rule.match = match
+	}
+	return nil
+}
+
+// errorKeywordRules returns rules treating Info statements containing any of
+// the keywords as errors. Keywords are case-insensitive and can be anchored
+// to the start or end of the message with ^ or $.
+func errorKeywordRules(keywords []string) []SeverityRule {
+	rules := make([]SeverityRule, 0, len(keywords))
+	severity := SeverityError
+	for _, keyword := range keywords {
+		expr := regexp.QuoteMeta(strings.TrimSuffix(strings.TrimPrefix(keyword, "^"), "$"))
+		if strings.HasPrefix(keyword, "^") {
+			expr = `^\s*` + expr
+		} else if strings.HasSuffix(keyword, "$") {
+			expr = expr + `\s*$`
+		}
+		rules = append(rules, SeverityRule{
+			Name:       "error-keyword:" + keyword,
+			Match:      "(?i)" + expr,
+			Severities: []Severity{SeverityInfo},
+			Severity:   &severity,
+		})
+	}
+	return rules
+}
+
+// apply reclassifies the statement using the first matching rule, recording
+// its original severity and the name of the rule.
+func (r *SeverityRules) apply(stmt *LogStatement) {
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if !rule.matches(stmt) {
+			continue
+		}
+		if *rule.Severity != stmt.Severity {
+			original := stmt.Severity
+			stmt.OriginalSeverity = &original
+			stmt.Severity = *rule.Severity
+			stmt.SeverityRule = rule.Name
+		}
+		return
+	}
+}
+
+func (rule *SeverityRule) matches(stmt *LogStatement) bool {
+	if len(rule.Severities) > 0 {
+		found := false
+		for _, s := range rule.Severities {
+			found = found || s == stmt.Severity
+		}
+		if !found {
+			return false
+		}
+	}
+	if rule.MinVerbosity != nil || rule.MaxVerbosity != nil {
+		if stmt.VerbosityUnknown {
+			return false
+		}
+		v := 0
+		if stmt.Verbosity != nil {
+			v = *stmt.Verbosity
+		}
+		if (rule.MinVerbosity != nil && v < *rule.MinVerbosity) ||
+			(rule.MaxVerbosity != nil && v > *rule.MaxVerbosity) {
+			return false
+		}
+	}
+	if len(rule.Packages) > 0 && !matchAny(rule.Packages, stmt.Package) {
+		return false
+	}
+	if len(rule.Files) > 0 && !matchAny(rule.Files, filepath.ToSlash(stmt.SourceFile)) {
+		return false
+	}
+	if rule.match != nil {
+		if stmt.DynamicFormat {
+			return false
+		}
+		message := stmt.FormatString
+		if unquoted, err := strconv.Unquote(message); err == nil {
+			message = unquoted
+		}
+		if !rule.match.MatchString(message) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAny reports whether name matches any of the patterns. Patterns ending
+// in /... match the path and everything below it, patterns without a slash
+// match the last path element, and other patterns use path.Match syntax.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if prefix := strings.TrimSuffix(pattern, "/..."); prefix != pattern {
+			if name == prefix || strings.HasPrefix(name, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		target := name
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(name)
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package inator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSeverityRules(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "rules.yaml")
+	err := os.WriteFile(filename, []byte(`
+rules:
+- name: kubelet-failures
+  match: '(?i)^failed to'
+  packages: [k8s.io/kubernetes/pkg/kubelet/...]
+  severities: [info]
+  severity: warning
+- name: noisy-errors
+  files: [noisy.go]
+  minVerbosity: 4
+  severity: info
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadSeverityRules(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules.Rules = append(rules.Rules, errorKeywordRules([]string{"^error", "timeout$"})...)
+	if err := rules.compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	v5 := 5
+	cases := []struct {
+		stmt     LogStatement
+		severity Severity
+		rule     string
+	}{
+		{LogStatement{Package: "k8s.io/kubernetes/pkg/kubelet/cm", FormatString: `"Failed to start %s"`}, SeverityWarning, "kubelet-failures"},
+		{LogStatement{Package: "k8s.io/kubernetes/pkg/proxy", FormatString: `"Failed to start %s"`}, SeverityInfo, ""},
+		{LogStatement{SourceFile: "pkg/a/noisy.go", Severity: SeverityError, Verbosity: &v5, FormatString: `"x"`}, SeverityInfo, "noisy-errors"},
+		{LogStatement{SourceFile: "pkg/a/noisy.go", Severity: SeverityError, FormatString: `"x"`}, SeverityError, ""},
+		{LogStatement{FormatString: `" Error syncing"`}, SeverityError, "error-keyword:^error"},
+		{LogStatement{FormatString: `"request timeout"`}, SeverityError, "error-keyword:timeout$"},
+		{LogStatement{FormatString: `"timeout waiting"`}, SeverityInfo, ""},
+		{LogStatement{FormatString: `fmt.Sprintf("error")`, DynamicFormat: true}, SeverityInfo, ""},
+	}
+	for _, c := range cases {
+		stmt := c.stmt
+		original := stmt.Severity
+		rules.apply(&stmt)
+		if stmt.Severity != c.severity || stmt.SeverityRule != c.rule {
+			t.Errorf("%s: expected %s by rule %q, got %s by rule %q", c.stmt.FormatString, c.severity, c.rule, stmt.Severity, stmt.SeverityRule)
+		}
+		if c.rule != "" && (stmt.OriginalSeverity == nil || *stmt.OriginalSeverity != original) {
+			t.Errorf("%s: expected original severity %s to be recorded", c.stmt.FormatString, original)
+		}
+	}
+}
+
+func TestSeverityRulesRequireSeverity(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "rules.yaml")
+	err := os.WriteFile(filename, []byte(`
+rules:
+- name: no-severity
+  match: '^failed'
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSeverityRules(filename); err == nil {
+		t.Error("expected an error for a rule without a severity")
+	}
+}
@@ -1,344 +1,693 @@
 package inator
 
 import (
-	"encoding/json"
 	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
-	"log"
+	"go/types"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+
+	"golang.org/x/tools/go/packages"
 )
 
 type SearchList []*LogStatement
-type SearchMap map[string]*LogStatement
+
+// SearchMap maps fingerprints to the statements with that fingerprint. More
+// than one statement has the same fingerprint if their files have the same
+// name and parent directory, such as options/options.go in different
+// components; Match tells them apart by the logged message.
+type SearchMap map[string][]*LogStatement
 
 type klogFunctionMeta struct {
 	Severity        int32
 	FormatStringPos int
 	MinArgs         int
+	// Structured functions take alternating keys and values after the message.
+	Structured bool
+	// Depth functions take the stack depth to attribute the statement to as
+	// their first argument.
+	Depth bool
+	// Verbosity is the fixed V-level of the function, if any.
+	Verbosity *int
+	Message   MessageStyle
 }
 
-var severityMap = map[string]klogFunctionMeta{
-	"Info":         {Severity: 0, FormatStringPos: 0},
-	"InfoDepth":    {Severity: 0, FormatStringPos: 1, MinArgs: 1},
-	"Infoln":       {Severity: 0, FormatStringPos: 0},
-	"Infof":        {Severity: 0, FormatStringPos: 0, MinArgs: 1},
-	"InfoS":        {Severity: 0, FormatStringPos: 0, MinArgs: 1},
-	"InfoSDepth":   {Severity: 0, FormatStringPos: 1, MinArgs: 2},
-	"Warning":      {Severity: 1, FormatStringPos: 0},
-	"WarningDepth": {Severity: 1, FormatStringPos: 1, MinArgs: 1},
-	"Warningln":    {Severity: 1, FormatStringPos: 0},
-	"Warningf":     {Severity: 1, FormatStringPos: 0, MinArgs: 1},
-	"Error":        {Severity: 2, FormatStringPos: 0},
-	"ErrorDepth":   {Severity: 2, FormatStringPos: 1, MinArgs: 1},
-	"Errorln":      {Severity: 2, FormatStringPos: 0},
-	"Errorf":       {Severity: 2, FormatStringPos: 0, MinArgs: 1},
-	"ErrorS":       {Severity: 2, FormatStringPos: 1, MinArgs: 2},
-	"ErrorSDepth":  {Severity: 2, FormatStringPos: 2, MinArgs: 3},
-	"Fatal":        {Severity: 3, FormatStringPos: 0},
-	"FatalDepth":   {Severity: 3, FormatStringPos: 1, MinArgs: 1},
-	"Fatalln":      {Severity: 3, FormatStringPos: 0},
-	"Fatalf":       {Severity: 3, FormatStringPos: 0, MinArgs: 1},
-	"Exit":         {Severity: 3, FormatStringPos: 0},
-	"ExitDepth":    {Severity: 3, FormatStringPos: 1, MinArgs: 1},
-	"Exitln":       {Severity: 3, FormatStringPos: 0},
-	"Exitf":        {Severity: 3, FormatStringPos: 0, MinArgs: 1},
+// LoadSearchList reads the statements from a search list file.
+func LoadSearchList(filename string) (SearchList, error) {
+	file, err := LoadSearchListFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return file.Statements, nil
 }
 
-func LoadSearchList(filename string) (SearchList, error) {
+// LoadSearchListFile reads a search list file along with its header. See
+// ReadSearchListFile for the supported formats.
+func LoadSearchListFile(filename string) (*SearchListFile, error) {
 	f, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
-
-	var list []*LogStatement
-	dec := json.NewDecoder(f)
-	if err := dec.Decode(&list); err != nil {
-		return nil, err
+	file, err := ReadSearchListFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
 	}
-	return list, nil
+	return file, nil
 }
 
+// GenerateSearchMap returns the search map of the statements, and the
+// statements whose fingerprints collide, by fingerprint.
 func (s SearchList) GenerateSearchMap() (sm SearchMap, collisions map[string][]*LogStatement) {
 	collisions = make(map[string][]*LogStatement)
 	sm = SearchMap{}
 	for _, stmt := range s {
 		fp := stmt.Fingerprint()
-		if existing, ok := sm[fp]; !ok {
-			sm[fp] = stmt
-		} else {
-			if _, ok := collisions[fp]; !ok {
-				collisions[fp] = []*LogStatement{existing}
-			}
-			collisions[fp] = append(collisions[fp], stmt)
+		sm[fp] = append(sm[fp], stmt)
+		if len(sm[fp]) > 1 {
+			collisions[fp] = sm[fp]
 		}
 	}
 	return
 }
 
-func resolveSeverity(
-	message string,
-	severity Severity,
-	errorKeywords []string,
-) (newSeverity Severity) {
-	if severity != SeverityInfo {
-		return severity
+type SearchOptions struct {
+	buildTags        []string
+	goos             string
+	goarch           string
+	modFlag          string
+	excludeModules   []string
+	excludeFilenames []string
+	errorKeywords    []string
+	typeCheck        bool
+	buildConfigs     []BuildConfig
+	tests            bool
+	registries       []*Registry
+	severityRules    []SeverityRule
+	cache            *Cache
+
+	// compiled from the default registry and registries
+	registry *registry
+	// set in syntactic mode if a cache is used
+	cacheSession *cacheSession
+	// set by Lint to check every call found
+	linter *linter
+}
+
+type SearchOption func(*SearchOptions)
+
+func (o *SearchOptions) Apply(opts ...SearchOption) {
+	for _, op := range opts {
+		op(o)
+	}
+}
+
+// WithBuildTags sets the build tags used when loading packages.
+func WithBuildTags(tags ...string) SearchOption {
+	return func(o *SearchOptions) {
+		o.buildTags = append(o.buildTags, tags...)
+	}
+}
+
+// WithGOOS overrides the target operating system used when loading packages.
+func WithGOOS(goos string) SearchOption {
+	return func(o *SearchOptions) {
+		o.goos = goos
+	}
+}
+
+// WithGOARCH overrides the target architecture used when loading packages.
+func WithGOARCH(goarch string) SearchOption {
+	return func(o *SearchOptions) {
+		o.goarch = goarch
+	}
+}
+
+// WithModFlag sets the -mod build flag (readonly, vendor, or mod).
+func WithModFlag(mod string) SearchOption {
+	return func(o *SearchOptions) {
+		o.modFlag = mod
+	}
+}
+
+// WithExcludeModules skips packages whose import path contains any of the
+// given substrings.
+func WithExcludeModules(substrings ...string) SearchOption {
+	return func(o *SearchOptions) {
+		o.excludeModules = append(o.excludeModules, substrings...)
+	}
+}
+
+// WithExcludeFilenames skips files whose name contains any of the given
+// substrings.
+func WithExcludeFilenames(substrings ...string) SearchOption {
+	return func(o *SearchOptions) {
+		o.excludeFilenames = append(o.excludeFilenames, substrings...)
+	}
+}
+
+// WithErrorKeywords treats Info statements containing any of the given
+// keywords as errors. Keywords are case-insensitive and can be anchored to
+// the start or end of the message with ^ or $. They are applied after any
+// rules added with WithSeverityRules.
+func WithErrorKeywords(keywords ...string) SearchOption {
+	return func(o *SearchOptions) {
+		o.errorKeywords = append(o.errorKeywords, keywords...)
+	}
+}
+
+// WithSeverityRules reclassifies the severity of the statements found using
+// the given rules.
+func WithSeverityRules(rules *SeverityRules) SearchOption {
+	return func(o *SearchOptions) {
+		o.severityRules = append(o.severityRules, rules.Rules...)
+	}
+}
+
+// WithTypeCheck enables type-checked detection of klog calls. Instead of
+// matching identifier names against the klog import alias, every call is
+// resolved using go/types, which also finds calls made through klog.Verbose
+// variables, method values, and parameters.
+func WithTypeCheck(enabled bool) SearchOption {
+	return func(o *SearchOptions) {
+		o.typeCheck = enabled
+	}
+}
+
+// WithBuildConfigs searches the packages once for each of the given build
+// configurations and merges the results. Each statement is tagged with the
+// configurations it was found in. Build tags set with WithBuildTags are added
+// to the tags of every configuration.
+func WithBuildConfigs(configs ...BuildConfig) SearchOption {
+	return func(o *SearchOptions) {
+		o.buildConfigs = append(o.buildConfigs, configs...)
+	}
+}
+
+// WithTests includes _test.go files in the search.
+func WithTests(enabled bool) SearchOption {
+	return func(o *SearchOptions) {
+		o.tests = enabled
+	}
+}
+
+// WithRegistry adds the logging functions in the given registry to the
+// default registry. Entries for functions which are already known replace
+// the default ones.
+func WithRegistry(reg *Registry) SearchOption {
+	return func(o *SearchOptions) {
+		o.registries = append(o.registries, reg)
+	}
+}
+
+// WithCache reuses the statements found in files which did not change since
+// a previous search with the same registries, and stores the results for the
+// files which did. The cache is only used by syntactic searches, since the
+// results of type-checked searches depend on other files as well.
+func WithCache(cache *Cache) SearchOption {
+	return func(o *SearchOptions) {
+		o.cache = cache
+	}
+}
+
+func (o *SearchOptions) env(config BuildConfig) []string {
+	env := os.Environ()
+	if config.GOOS != "" {
+		env = append(env, "GOOS="+config.GOOS)
+	}
+	if config.GOARCH != "" {
+		env = append(env, "GOARCH="+config.GOARCH)
+	}
+	return env
+}
+
+func (o *SearchOptions) buildFlags(config BuildConfig) []string {
+	var flags []string
+	tags := append(append([]string{}, o.buildTags...), config.Tags...)
+	if len(tags) > 0 {
+		flags = append(flags, "-tags="+strings.Join(tags, ","))
+	}
+	if o.modFlag != "" {
+		flags = append(flags, "-mod="+o.modFlag)
+	}
+	return flags
+}
+
+func loadPackages(patterns []string, options *SearchOptions, config BuildConfig) ([]*packages.Package, error) {
+	mode := packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedModule
+	if options.typeCheck {
+		// Dependencies are type-checked from source so that objects from klog
+		// are shared between all loaded packages.
+		mode |= packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedTypesSizes | packages.NeedDeps
+	}
+	cfg := &packages.Config{
+		Mode:       mode,
+		Env:        options.env(config),
+		BuildFlags: options.buildFlags(config),
+		Tests:      options.tests,
+	}
+	return packages.Load(cfg, patterns...)
+}
+
+// Search loads the packages matching the given patterns and returns every
+// log statement found in them. Problems with individual packages or files do
+// not stop the search, and are reported in the diagnostics of the result
+// instead. An error is only returned if the packages could not be loaded at
+// all (in any of the build configurations), or the options are invalid.
+func Search(patterns []string, opts ...SearchOption) (*SearchResult, error) {
+	options := SearchOptions{}
+	options.Apply(opts...)
+	options.registry = compileRegistry(append([]*Registry{DefaultRegistry()}, options.registries...)...)
+
+	rules := &SeverityRules{
+		Rules: append(append([]SeverityRule{}, options.severityRules...), errorKeywordRules(options.errorKeywords)...),
+	}
+	if err := rules.compile(); err != nil {
+		return nil, err
+	}
+
+	if options.cache != nil && !options.typeCheck && options.linter == nil {
+		options.cacheSession = newCacheSession(options.cache, &options)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	configs := options.buildConfigs
+	matrix := len(configs) > 0
+	if !matrix {
+		configs = []BuildConfig{{GOOS: options.goos, GOARCH: options.goarch}}
 	}
-	defer func() {
-		if newSeverity == SeverityError {
-			fmt.Fprintf(os.Stderr, "Treating info message as error: %s\n", message)
+	result := &SearchResult{}
+	// The same statement can be found in several build configurations, and
+	// also in both the regular and test variant of a package when tests are
+	// enabled.
+	merged := map[string]*LogStatement{}
+	var loadErr error
+	numLoaded := 0
+	for _, config := range configs {
+		diags := &diagnosticLog{}
+		if matrix {
+			diags.buildConfig = config.String()
+		}
+		pkgs, err := loadPackages(patterns, &options, config)
+		if err != nil {
+			loadErr = err
+			diags.add(Diagnostic{Kind: DiagnosticLoadError, Message: err.Error()})
+			result.Diagnostics = append(result.Diagnostics, diags.list...)
+			continue
 		}
-	}()
-	unquoted := strings.ToLower(strings.Trim(message, ` "`))
-	for _, keyword := range errorKeywords {
-		if keyword[0] == '^' {
-			if strings.HasPrefix(unquoted, strings.ToLower(keyword[1:])) {
-				return SeverityError
+		numLoaded++
+		withLog, others := filterPackages(pkgs, &options, diags)
+		for _, stmt := range searchPackages(withLog, others, &options, wd, diags) {
+			rules.apply(stmt)
+			key := fmt.Sprintf("%s:%d:%d:%s", stmt.SourceFile, stmt.LineNumber, stmt.Severity, stmt.FormatString)
+			existing, ok := merged[key]
+			if !ok {
+				merged[key] = stmt
+				result.Statements = append(result.Statements, stmt)
+				existing = stmt
 			}
-		} else if keyword[len(keyword)-1] == '$' {
-			if strings.HasSuffix(unquoted, strings.ToLower(keyword[:len(keyword)-1])) {
-				return SeverityError
-			}
-		} else {
-			if strings.Contains(unquoted, strings.ToLower(keyword)) {
-				return SeverityError
+			if matrix {
+				existing.addBuildConfig(config.String())
 			}
 		}
+		sortDiagnostics(diags.list)
+		result.Diagnostics = append(result.Diagnostics, diags.list...)
+	}
+	result.Statements.AssignIDs()
+	if options.cacheSession != nil {
+		result.CacheHits = int(options.cacheSession.hits.Load())
+		result.CacheMisses = int(options.cacheSession.misses.Load())
 	}
-	return SeverityInfo
+	if numLoaded == 0 {
+		return result, loadErr
+	}
+	return result, nil
 }
 
-func Search(
-	jsonObjects []string,
-	excludeModules []string,
-	excludeFilenames []string,
-	errorKeywords []string,
-) <-chan *LogStatement {
-	wd, err := os.Getwd()
-	if err != nil {
-		log.Fatal(err)
-	}
-	var wg sync.WaitGroup
-	wg.Add(len(jsonObjects))
-	searchPackages := make([]*internalPackage, len(jsonObjects))
-	for i, doc := range jsonObjects {
-		go func(i int, doc string) {
-			defer wg.Done()
-			pkg := &internalPackage{}
-			if err := json.Unmarshal([]byte(doc), &pkg); err != nil {
-				fmt.Println(doc)
-				log.Fatal("error parsing json: " + err.Error())
+// filterPackages returns the packages which could contain log statements,
+// and the remaining packages which are not excluded by the search options.
+func filterPackages(pkgs []*packages.Package, options *SearchOptions, diags *diagnosticLog) (withLog, others []*packages.Package) {
+	withLog = make([]*packages.Package, 0, len(pkgs))
+PACKAGES:
+	for _, pkg := range pkgs {
+		for _, exclude := range options.excludeModules {
+			if strings.Contains(pkg.PkgPath, exclude) {
+				diags.add(Diagnostic{
+					Kind:    DiagnosticPackageExcluded,
+					Package: pkg.PkgPath,
+					Message: "matches excluded module " + strconv.Quote(exclude),
+				})
+				continue PACKAGES
 			}
-			klogFound := false
-			for _, im := range pkg.Imports {
-				if im == "k8s.io/klog/v2" {
-					klogFound = true
-				}
+		}
+		for _, err := range pkg.Errors {
+			diags.add(Diagnostic{
+				Kind:    DiagnosticPackageError,
+				Package: pkg.PkgPath,
+				Message: err.Error(),
+			})
+		}
+		var importsLog bool
+		for path := range pkg.Imports {
+			// Without type information, only calls starting at a
+			// package-level function can be found.
+			if options.typeCheck {
+				importsLog = importsLog || options.registry.hasPackage(path)
+			} else {
+				importsLog = importsLog || options.registry.hasPackageFuncs(path)
 			}
-			for _, exclude := range excludeModules {
-				if strings.Contains(pkg.ImportPath, exclude) {
-					fmt.Fprintf(os.Stderr, "Excluding package %s\n", pkg.ImportPath)
-					return
+		}
+		if importsLog {
+			withLog = append(withLog, pkg)
+		} else {
+			others = append(others, pkg)
+		}
+	}
+	return withLog, others
+}
+
+// searchedFile is a parsed file from one of the searched packages.
+type searchedFile struct {
+	pkg      *packages.Package
+	ast      *ast.File
+	fset     *token.FileSet
+	relPath  string
+	resolver callResolver
+	origin   provenance
+
+	// callees are the keys of the functions called in a file restored from
+	// the cache, which has not been parsed. load parses it on demand.
+	callees map[string]bool
+	load    func() bool
+}
+
+// searchPackages searches each package in its own goroutine. Statements
+// logged through wrapper functions are attributed to the call sites of the
+// wrappers, which are looked for in both pkgs and others.
+func searchPackages(pkgs, others []*packages.Package, options *SearchOptions, wd string, diags *diagnosticLog) []*LogStatement {
+	var mu sync.Mutex
+	var statements []*LogStatement
+	var wrappers []wrapper
+	var files []*searchedFile
+	wg := sync.WaitGroup{}
+	wg.Add(len(pkgs))
+	for _, pkg := range pkgs {
+		go func(pkg *packages.Package) {
+			defer wg.Done()
+			var pkgFiles []*searchedFile
+			var pkgStatements []*LogStatement
+			var pkgWrappers []wrapper
+			if options.cacheSession != nil {
+				pkgFiles, pkgStatements, pkgWrappers = options.cacheSession.searchPackage(pkg, options, wd, diags)
+			} else {
+				pkgFiles = parsePackage(pkg, options, wd, diags)
+				for _, sf := range pkgFiles {
+					func() {
+						defer diags.recover(pkg.PkgPath, sf.relPath)
+						stmts, ws := searchFile(sf, options)
+						pkgStatements = append(pkgStatements, stmts...)
+						pkgWrappers = append(pkgWrappers, ws...)
+					}()
 				}
 			}
-			if klogFound {
-				searchPackages[i] = pkg
-			}
-		}(i, doc)
+			mu.Lock()
+			statements = append(statements, pkgStatements...)
+			wrappers = append(wrappers, pkgWrappers...)
+			files = append(files, pkgFiles...)
+			mu.Unlock()
+		}(pkg)
 	}
 	wg.Wait()
 
-	// remove nil entries from searchPackages
-	packagesWithLog := make([]*internalPackage, 0, len(searchPackages))
-	for _, pkg := range searchPackages {
-		if pkg != nil {
-			packagesWithLog = append(packagesWithLog, pkg)
+	if len(wrappers) == 0 {
+		return statements
+	}
+	for _, pkg := range others {
+		if options.cacheSession != nil {
+			pkgFiles, _, _ := options.cacheSession.searchPackage(pkg, options, wd, diags)
+			files = append(files, pkgFiles...)
+			continue
 		}
+		files = append(files, parsePackage(pkg, options, wd, diags)...)
 	}
+	return append(statements, expandWrappers(wrappers, files)...)
+}
 
-	wg = sync.WaitGroup{}
-	wg.Add(len(packagesWithLog))
-	logStatements := make(chan *LogStatement, len(packagesWithLog))
+// parsePackage returns the files of the package which are not excluded by
+// the search options. In type-checked mode, the syntax trees loaded along
+// with the package are used, otherwise the files are parsed here. Files which
+// cannot be parsed are skipped.
+func parsePackage(pkg *packages.Package, options *SearchOptions, wd string, diags *diagnosticLog) []*searchedFile {
+	fileset := pkg.Fset
+	files := pkg.Syntax
+	if !options.typeCheck {
+		fileset = token.NewFileSet()
+		files = make([]*ast.File, 0, len(pkg.GoFiles))
+		for _, file := range pkg.GoFiles {
+			f, err := parser.ParseFile(fileset, file, nil, parser.ParseComments)
+			if err != nil {
+				diags.add(Diagnostic{
+					Kind:    DiagnosticParseError,
+					Package: pkg.PkgPath,
+					File:    file,
+					Message: err.Error(),
+				})
+				continue
+			}
+			files = append(files, f)
+		}
+	}
+	origin := newProvenance(pkg)
+	consts := collectConsts(files)
+	searchedFiles := make([]*searchedFile, 0, len(files))
+	for _, f := range files {
+		filename := fileset.Position(f.Pos()).Filename
+		if excludeFile(pkg, filename, options, diags) {
+			continue
+		}
+		searchedFiles = append(searchedFiles, newSearchedFile(pkg, fileset, f, relativePath(wd, filename), origin, consts, options))
+	}
+	return searchedFiles
+}
 
-	for _, pkgWithLog := range packagesWithLog {
-		go func(pkgWithLog *internalPackage) {
-			defer wg.Done()
-			fileset := token.NewFileSet()
-			for _, file := range pkgWithLog.GoFiles {
-				for _, exclude := range excludeFilenames {
-					if strings.Contains(file, exclude) {
-						fmt.Fprintf(os.Stderr, "Excluding file %s\n", file)
-						return
+// excludeFile reports whether the file is excluded by the search options.
+func excludeFile(pkg *packages.Package, filename string, options *SearchOptions, diags *diagnosticLog) bool {
+	for _, exclude := range options.excludeFilenames {
+		if strings.Contains(filepath.Base(filename), exclude) {
+			diags.add(Diagnostic{
+				Kind:    DiagnosticFileExcluded,
+				Package: pkg.PkgPath,
+				File:    filename,
+				Message: "matches excluded file name " + strconv.Quote(exclude),
+			})
+			return true
+		}
+	}
+	return false
+}
+
+// relativePath returns filename relative to wd, or filename itself if it
+// cannot be made relative.
+func relativePath(wd, filename string) string {
+	relPath, err := filepath.Rel(wd, filename)
+	if err != nil {
+		return filename
+	}
+	return relPath
+}
+
+// newSearchedFile returns a file of the package to be searched. consts are the
+// constants declared in all files of the package, which are only used
+// without type information.
+func newSearchedFile(pkg *packages.Package, fset *token.FileSet, f *ast.File, relPath string, origin provenance, consts packageConsts, options *SearchOptions) *searchedFile {
+	sf := &searchedFile{
+		pkg:     pkg,
+		ast:     f,
+		fset:    fset,
+		relPath: relPath,
+		origin:  origin,
+	}
+	if options.typeCheck {
+		sf.resolver = newTypedResolver(pkg.TypesInfo, f, options.registry)
+	} else {
+		sf.resolver = newSyntacticResolver(f, pkg.PkgPath, options.registry, consts)
+	}
+	return sf
+}
+
+// searchFile returns the log statements in the file. Statements which pass a
+// constant depth to one of the *Depth functions are returned as wrappers
+// instead, since klog attributes them to a caller of the enclosing function.
+// If that function cannot be called by name, e.g. a function literal, the
+// statement is left out, since it is never logged at its own location.
+func searchFile(sf *searchedFile, options *SearchOptions) ([]*LogStatement, []wrapper) {
+	var statements []*LogStatement
+	var wrappers []wrapper
+	resolver := sf.resolver
+	forEachDecl(sf.ast, func(node ast.Node, enclosing, receiver string, callable bool) {
+		// find any calls to the functions in the registry
+		inspectCalls(node, func(call *ast.CallExpr, direct bool) bool {
+			lc, ok := resolver.resolve(call)
+			if !ok {
+				return true
+			}
+			if len(call.Args) < lc.meta.MinArgs {
+				return true
+			}
+
+			var format string
+			var dynamicFormat bool
+			if len(call.Args) > lc.meta.FormatStringPos {
+				format, dynamicFormat = evalFormat(call.Args[lc.meta.FormatStringPos], resolver.constValue)
+			}
+			var keysAndValues []KeyValue
+			if lc.meta.Structured && len(call.Args) > lc.meta.FormatStringPos+1 {
+				keysAndValues = evalKeysAndValues(call, lc.meta.FormatStringPos+1, resolver.constValue)
+			}
+			var verbosity *int
+			var verbosityExpr string
+			var verbosityUnknown bool
+			if len(lc.verbosity) > 0 {
+				if v, ok := evalVerbosity(lc.verbosity, resolver.constValue); ok {
+					verbosity = &v
+				} else {
+					verbosityUnknown = true
+				}
+				if lit, ok := lc.verbosity[0].(*ast.BasicLit); !ok || len(lc.verbosity) > 1 || lit.Kind != token.INT {
+					exprs := make([]string, len(lc.verbosity))
+					for i, expr := range lc.verbosity {
+						exprs[i] = types.ExprString(expr)
 					}
+					verbosityExpr = strings.Join(exprs, " + ")
 				}
-				f, err := parser.ParseFile(fileset, filepath.Join(pkgWithLog.Dir, file), nil, parser.ParseComments)
-				if err != nil {
-					log.Fatal("error parsing file: " + err.Error())
+			}
+			if lc.meta.Verbosity != nil && !verbosityUnknown {
+				v := *lc.meta.Verbosity
+				if verbosity != nil {
+					v += *verbosity
 				}
-				// find klog import
-				klogPackageName := "klog"
-				for _, im := range f.Imports {
-					if im.Path.Value == `"k8s.io/klog/v2"` {
-						// get klog package name
-						if name := im.Name.String(); name != "<nil>" && name != "" && name != "." {
-							klogPackageName = im.Name.Name
-						}
+				verbosity = &v
+			}
+			stmt := &LogStatement{
+				SourceFile:       sf.relPath,
+				LineNumber:       sf.fset.Position(call.Pos()).Line,
+				Severity:         Severity(lc.meta.Severity),
+				Verbosity:        verbosity,
+				VerbosityExpr:    verbosityExpr,
+				VerbosityUnknown: verbosityUnknown,
+				FormatString:     format,
+				DynamicFormat:    dynamicFormat,
+				MessageStyle:     lc.meta.Message,
+				KeysAndValues:    keysAndValues,
+				Contextual:       lc.contextual,
+				Function:         enclosing,
+				Receiver:         receiver,
+			}
+			setCallContext(stmt, sf, call)
+			stmt.Risks = assessRisks(call, lc.meta, stmt, resolver)
+			sf.origin.apply(stmt)
+			if options.linter != nil {
+				options.linter.check(sf, call, lc, stmt)
+			}
+			if lc.meta.Depth {
+				if depth, ok := evalInt(call.Args[0], resolver.constValue); ok && depth > 0 {
+					if callable && direct {
+						wrappers = append(wrappers, wrapper{
+							key:   wrapperKey(sf.pkg.PkgPath, enclosing, receiver),
+							depth: depth,
+							stmt:  stmt,
+						})
 					}
+					return false
 				}
-				if klogPackageName == "" {
-					panic("bug")
-				}
-				fileName := file
-				relPath, err := filepath.Rel(wd, filepath.Join(pkgWithLog.Dir, fileName))
-				if err != nil {
-					log.Fatal(err)
+			}
+			statements = append(statements, stmt)
+			return false
+		})
+	})
+	return statements, wrappers
+}
+
+// setCallContext records the end position and argument texts of the call.
+func setCallContext(stmt *LogStatement, sf *searchedFile, call *ast.CallExpr) {
+	end := sf.fset.Position(call.End())
+	stmt.EndLineNumber = end.Line
+	stmt.EndColumn = end.Column
+	stmt.Arguments = make([]string, len(call.Args))
+	for i, arg := range call.Args {
+		stmt.Arguments[i] = types.ExprString(arg)
+	}
+}
+
+// forEachDecl calls fn with the body of every function declaration and the
+// initializer of every variable declared at package level in the file, along
+// with the name of the enclosing function or variable, and the receiver type
+// of methods. callable is true if node is the body of a function which can be
+// called by that name, i.e. a function or method declaration or a variable
+// initialized with a function literal.
+func forEachDecl(f *ast.File, fn func(node ast.Node, enclosing, receiver string, callable bool)) {
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Body == nil {
+				continue
+			}
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				fn(decl.Body, decl.Name.Name, types.ExprString(decl.Recv.List[0].Type), true)
+			} else {
+				fn(decl.Body, decl.Name.Name, "", decl.Recv == nil)
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				vs, ok := spec.(*ast.ValueSpec)
+				if !ok {
+					continue
 				}
-				for _, decl := range f.Decls {
-					fn, ok := decl.(*ast.FuncDecl)
-					if !ok {
+				for i, value := range vs.Values {
+					if len(vs.Names) == len(vs.Values) {
+						_, isFunc := value.(*ast.FuncLit)
+						fn(value, vs.Names[i].Name, "", isFunc)
 						continue
 					}
-					// find any calls to klog.v2
-					ast.Inspect(fn.Body, func(n ast.Node) bool {
-						call, ok := n.(*ast.CallExpr)
-						if !ok {
-							return true
-						}
-						fun, ok := call.Fun.(*ast.SelectorExpr)
-						if !ok {
-							return true
-						}
-						// Check if the function name matches one of the klog functions
-						var meta klogFunctionMeta
-						if m, ok := severityMap[fun.Sel.Name]; ok {
-							meta = m
-						} else {
-							return true
-						}
-
-						// At this point we do not yet know for sure if this is a klog call
-
-						// Try to match one of the two possible formats:
-						// 1. klog.FunctionName(...)
-						// 2. klog.V(...).FunctionName(...)
-						//
-						// Below, X is either an Ident or CallExpr, respectively:
-						// 1. klog.FunctionName(...)
-						//    ^^^^
-						// 2. klog.V(...).FunctionName(...)
-						//    ^^^^^^^^^^^
-						if len(call.Args) < meta.MinArgs {
-							return true
-						}
-
-						var stringLiteralFmtArg string
-						// In both cases, the arg to FunctionName (as shown above) must
-						// either be a BasicLit of kind STRING, or an Ident.
-
-						if len(call.Args) > meta.FormatStringPos {
-							switch arg := call.Args[meta.FormatStringPos].(type) {
-							case *ast.BasicLit:
-								if arg.Kind != token.STRING {
-									return true
-								}
-								stringLiteralFmtArg = arg.Value
-							case *ast.Ident:
-							default:
-								return true
-							}
-						}
-
-						switch ex := fun.X.(type) {
-						case *ast.Ident:
-							// In this case, the following must be true of the Ident:
-							// 1. It has X of type Ident with Name == klog
-
-							if ex.Name != klogPackageName {
-								return true
-							}
-
-							// This is a klog call of form 1
-							stmt := LogStatement{
-								SourceFile: relPath,
-								LineNumber: fileset.Position(call.Pos()).Line,
-								Severity: resolveSeverity(
-									stringLiteralFmtArg,
-									Severity(meta.Severity),
-									errorKeywords,
-								),
-								FormatString: stringLiteralFmtArg,
-							}
-							logStatements <- &stmt
-							return false
-						case *ast.CallExpr:
-							// In this case, the following must be true of the CallExpr:
-							// 1. It has len(Args)==1 and Args[0] is a BasicLit containing an INT value
-							// 2. It has Fun of type SelectorExpr which has:
-							//    - Sel of type Ident with Name == V
-							//    - X of type Ident with Name == klog
-
-							if len(ex.Args) != 1 {
-								return true
-							}
-							lit, ok := ex.Args[0].(*ast.BasicLit)
-							if !ok {
-								return true
-							}
-							if lit.Kind != token.INT {
-								return true
-							}
-							var verbosity *int
-							v, err := strconv.Atoi(lit.Value)
-							if err == nil {
-								verbosity = &v
-							}
-							// Check the V function name
-							vFunc, ok := ex.Fun.(*ast.SelectorExpr)
-							if !ok {
-								return true
-							}
-							if vFunc.Sel.Name != "V" {
-								return true
-							}
-							// Check the klog package name
-							ident, ok := vFunc.X.(*ast.Ident)
-							if !ok {
-								return true
-							}
-							if ident.Name != klogPackageName {
-								return true
-							}
-
-							// This is a klog call of form 2
-							stmt := LogStatement{
-								SourceFile: relPath,
-								LineNumber: fileset.Position(call.Pos()).Line,
-								Severity: resolveSeverity(
-									stringLiteralFmtArg,
-									Severity(meta.Severity),
-									errorKeywords,
-								),
-								Verbosity:    verbosity,
-								FormatString: stringLiteralFmtArg,
-							}
-							logStatements <- &stmt
-							return false
-						}
-						return true
-					})
+					// var a, b = f()
+					names := make([]string, len(vs.Names))
+					for j, name := range vs.Names {
+						names[j] = name.Name
+					}
+					fn(value, strings.Join(names, ", "), "", false)
+				}
+			}
+		}
+	}
+}
+
+// inspectCalls calls fn for every call expression in node, in the same order
+// as ast.Inspect. direct is false for calls inside function literals nested
+// in node. If fn returns false, the arguments of the call are not inspected.
+func inspectCalls(node ast.Node, fn func(call *ast.CallExpr, direct bool) bool) {
+	var walk func(root ast.Node, direct bool)
+	walk = func(root ast.Node, direct bool) {
+		ast.Inspect(root, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				if n != root {
+					walk(n, false)
+					return false
 				}
+			case *ast.CallExpr:
+				return fn(n, direct)
 			}
-		}(pkgWithLog)
+			return true
+		})
 	}
-	go func() {
-		wg.Wait()
-		close(logStatements)
-	}()
-	return logStatements
+	walk(node, true)
 }
@@ -0,0 +1,183 @@
+package inator_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kralicky/klog-inator/pkg/inator"
+)
+
+func TestLoadSearchListFile(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string]string{
+		"array.json":  `[{"sourceFile": "a/a.go", "lineNumber": 3, "severity": 2}]`,
+		"header.json": `{"header": {"goVersion": "go1.17", "patterns": ["./..."], "options": {}}, "statements": [{"sourceFile": "a/a.go", "lineNumber": 3, "severity": 2, "module": "example.com/a"}]}`,
+		"header.ndjson": `{"header": {"schemaVersion": 1, "goVersion": "go1.17", "patterns": ["./..."], "options": {}}}
+{"statement": {"id": "example.com/a.f#1", "sourceFile": "a/a.go", "lineNumber": 3, "severity": 2}}
+{"diagnostic": {"kind": "parse-error", "file": "a/b.go"}}
+`,
+	}
+	for name, contents := range cases {
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		file, err := inator.LoadSearchListFile(filename)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(file.Statements) != 1 || file.Statements[0].LineNumber != 3 || file.Statements[0].Severity != inator.SeverityError {
+			t.Errorf("%s: unexpected statements %+v", name, file.Statements)
+		}
+		if (file.Header != nil) != (name != "array.json") {
+			t.Errorf("%s: unexpected header %+v", name, file.Header)
+		}
+	}
+}
+
+func TestLoadSearchListFileVersion(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "list.json")
+	err := os.WriteFile(filename, []byte(`{"header": {"schemaVersion": 1000}, "statements": []}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inator.LoadSearchListFile(filename); err == nil {
+		t.Error("expected an error for an unsupported schema version")
+	}
+}
+
+func TestSearchListRoundTrip(t *testing.T) {
+	list := inator.SearchList{
+		{SourceFile: "b/b.go", LineNumber: 7, Package: "example.com/b", Function: "run"},
+		{SourceFile: "a/a.go", LineNumber: 20, Package: "example.com/a", Function: "f"},
+		{SourceFile: "a/a.go", LineNumber: 9, Package: "example.com/a", Function: "f"},
+		{SourceFile: "a/a.go", LineNumber: 3, Package: "example.com/a", Function: "init"},
+	}
+	list.AssignIDs()
+	wantIDs := []string{"example.com/a.init#1", "example.com/a.f#1", "example.com/a.f#2", "example.com/b.run#1"}
+	for i, stmt := range list {
+		if stmt.ID != wantIDs[i] {
+			t.Errorf("statement %d: got ID %q, want %q", i, stmt.ID, wantIDs[i])
+		}
+	}
+
+	file := &inator.SearchListFile{
+		Header:      &inator.SearchListHeader{SchemaVersion: inator.SearchListSchemaVersion},
+		Statements:  list,
+		Diagnostics: []inator.Diagnostic{{Kind: inator.DiagnosticParseError, File: "c/c.go"}},
+	}
+	for name, write := range map[string]func(w io.Writer) error{
+		"json":   file.WriteJSON,
+		"ndjson": file.WriteNDJSON,
+	} {
+		var buf bytes.Buffer
+		if err := write(&buf); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		read, err := inator.ReadSearchListFile(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(read, file) {
+			t.Errorf("%s: got %+v, want %+v", name, read, file)
+		}
+	}
+}
+
+// searchTestdata searches the module in testdata/name, which is loaded with
+// the dependencies listed in its own go.mod and go.sum.
+func searchTestdata(t *testing.T, name string, patterns []string, opts ...inator.SearchOption) *inator.SearchResult {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(filepath.Join("testdata", name)); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	t.Setenv("GOFLAGS", "-mod=readonly")
+	t.Setenv("GOWORK", "off")
+	result, err := inator.Search(patterns, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestSearchTypeCheck(t *testing.T) {
+	for _, typeCheck := range []bool{false, true} {
+		result := searchTestdata(t, "example", []string{"./server"}, inator.WithTypeCheck(typeCheck))
+		if errs := result.Errors(); len(errs) > 0 {
+			t.Fatalf("type check %v: unexpected errors %v", typeCheck, errs)
+		}
+		expected := []string{
+			`server/server.go:10 I "starting %s on port %d" (*Server).Run`,
+			`server/server.go:11 I "listening" (*Server).Run`,
+			`server/server.go:12 I "serving %s" (*Server).Run`,
+			`server/server.go:13 I "serving on port %d" (*Server).Run`,
+		}
+		if typeCheck {
+			expected = append(expected, `server/server.go:19 I "debugging" debug`)
+		}
+		var actual []string
+		for _, stmt := range result.Statements {
+			actual = append(actual, fmt.Sprintf("%s:%d %s %s %s", stmt.SourceFile, stmt.LineNumber, stmt.Severity, stmt.FormatString, stmt.QualifiedFunction()))
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("type check %v: got statements\n%s\nwant\n%s", typeCheck, strings.Join(actual, "\n"), strings.Join(expected, "\n"))
+		}
+	}
+}
+
+func TestSearchInfofDepth(t *testing.T) {
+	result := searchTestdata(t, "example", []string{"./server"})
+	var found int
+	for _, stmt := range result.Statements {
+		switch stmt.LineNumber {
+		case 12, 13:
+			found++
+			if stmt.MessageStyle != inator.MessagePrintf {
+				t.Errorf("line %d: got message style %q, want printf", stmt.LineNumber, stmt.MessageStyle)
+			}
+		}
+		if stmt.LineNumber == 13 && (stmt.Verbosity == nil || *stmt.Verbosity != 4) {
+			t.Errorf("line 13: got verbosity %s, want 4", stmt.VerbosityString())
+		}
+	}
+	if found != 2 {
+		t.Errorf("expected both klog.InfofDepth calls to be found, got %d", found)
+	}
+}
+
+func TestSearchPackageConsts(t *testing.T) {
+	cache, err := inator.OpenCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	searches := map[string][]inator.SearchOption{
+		"syntactic":  nil,
+		"type check": {inator.WithTypeCheck(true)},
+		"cold cache": {inator.WithCache(cache)},
+		"warm cache": {inator.WithCache(cache)},
+	}
+	for _, name := range []string{"syntactic", "type check", "cold cache", "warm cache"} {
+		result := searchTestdata(t, "example", []string{"./consts"}, searches[name]...)
+		if len(result.Statements) != 1 {
+			t.Fatalf("%s: expected 1 statement, got %d", name, len(result.Statements))
+		}
+		stmt := result.Statements[0]
+		if stmt.DynamicFormat || stmt.FormatString != `"syncing pods"` {
+			t.Errorf("%s: got format string %s (dynamic %v), want \"syncing pods\"", name, stmt.FormatString, stmt.DynamicFormat)
+		}
+		if stmt.Verbosity == nil || *stmt.Verbosity != 4 {
+			t.Errorf("%s: got verbosity %s, want 4", name, stmt.VerbosityString())
+		}
+	}
+}
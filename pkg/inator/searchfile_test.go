@@ -0,0 +1,133 @@
+package inator
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// searchSource searches the source of a file server/server.go in the package
+// example.com/server with the default registry.
+func searchSource(t *testing.T, src string, opts ...SearchOption) []*LogStatement {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "server.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := &SearchOptions{}
+	options.Apply(opts...)
+	options.registry = compileRegistry(append([]*Registry{DefaultRegistry()}, options.registries...)...)
+	pkg := &packages.Package{Name: f.Name.Name, PkgPath: "example.com/server"}
+	sf := newSearchedFile(pkg, fset, f, "server/server.go", provenance{}, collectConsts([]*ast.File{f}), options)
+	stmts, _ := searchFile(sf, options)
+	return stmts
+}
+
+func TestSearchFileContext(t *testing.T) {
+	src := `package server
+
+import "k8s.io/klog/v2"
+
+type Server struct{}
+
+func (s *Server) Run(name string) {
+	klog.Infof("starting %s",
+		name)
+}
+
+func start() {
+	klog.V(2).InfoS("started", "server", s.name)
+}
+`
+	stmts := searchSource(t, src)
+	if len(stmts) != 2 {
+		t.Fatalf("expected 2 statements, got %d", len(stmts))
+	}
+
+	run := stmts[0]
+	if run.QualifiedFunction() != "(*Server).Run" {
+		t.Errorf("got function %q, want (*Server).Run", run.QualifiedFunction())
+	}
+	if run.LineNumber != 8 || run.EndLineNumber != 9 || run.EndColumn != 8 {
+		t.Errorf("got position %d-%d:%d, want 8-9:8", run.LineNumber, run.EndLineNumber, run.EndColumn)
+	}
+	if want := []string{`"starting %s"`, "name"}; !reflect.DeepEqual(run.Arguments, want) {
+		t.Errorf("got arguments %q, want %q", run.Arguments, want)
+	}
+
+	start := stmts[1]
+	if start.QualifiedFunction() != "start" || start.Receiver != "" {
+		t.Errorf("got function %q, want start", start.QualifiedFunction())
+	}
+	if want := []string{`"started"`, `"server"`, "s.name"}; !reflect.DeepEqual(start.Arguments, want) {
+		t.Errorf("got arguments %q, want %q", start.Arguments, want)
+	}
+}
+
+func TestSearchFileContextual(t *testing.T) {
+	src := `package server
+
+import (
+	"context"
+
+	"k8s.io/klog/v2"
+)
+
+func serve(ctx context.Context, name string) {
+	klog.FromContext(ctx).Info("serving", "server", name)
+	logger := klog.FromContext(ctx).WithName("server")
+	logger.Error(nil, "failed", "attempt", 2)
+}
+`
+	stmts := searchSource(t, src)
+	if len(stmts) != 2 {
+		t.Fatalf("expected 2 statements, got %d", len(stmts))
+	}
+	info, failed := stmts[0], stmts[1]
+	if !info.Contextual || info.Severity != SeverityInfo || info.FormatString != `"serving"` || info.Function != "serve" {
+		t.Errorf("unexpected statement %+v", info)
+	}
+	if want := []KeyValue{{Key: "server", Value: "name"}}; !reflect.DeepEqual(info.KeysAndValues, want) {
+		t.Errorf("got keys and values %+v, want %+v", info.KeysAndValues, want)
+	}
+	if !failed.Contextual || failed.Severity != SeverityError || failed.Function != "serve" {
+		t.Errorf("unexpected statement %+v", failed)
+	}
+	if want := []KeyValue{{Key: "attempt", Value: "2"}}; !reflect.DeepEqual(failed.KeysAndValues, want) {
+		t.Errorf("got keys and values %+v, want %+v", failed.KeysAndValues, want)
+	}
+}
+
+func TestSearchFileVarInitializer(t *testing.T) {
+	src := `package server
+
+import "k8s.io/klog/v2"
+
+var logStart = func(name string) {
+	klog.InfoS("starting", "server", name)
+}
+
+var handlers = map[string]func(){
+	"stop": func() { klog.Warning("stopping") },
+}
+`
+	stmts := searchSource(t, src)
+	if len(stmts) != 2 {
+		t.Fatalf("expected 2 statements, got %d", len(stmts))
+	}
+	start, stop := stmts[0], stmts[1]
+	if start.Function != "logStart" || start.LineNumber != 6 || start.FormatString != `"starting"` {
+		t.Errorf("unexpected statement %+v", start)
+	}
+	if want := []KeyValue{{Key: "server", Value: "name"}}; !reflect.DeepEqual(start.KeysAndValues, want) {
+		t.Errorf("got keys and values %+v, want %+v", start.KeysAndValues, want)
+	}
+	if stop.Function != "handlers" || stop.LineNumber != 10 || stop.Severity != SeverityWarning {
+		t.Errorf("unexpected statement %+v", stop)
+	}
+}
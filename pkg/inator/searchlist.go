@@ -0,0 +1,250 @@
+package inator
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SearchListSchemaVersion is the version of the search list format written by
+// this package. Lists without a header (bare arrays of statements) or without
+// a version in their header are version 0.
+const SearchListSchemaVersion = 1
+
+// searchListRecord is a line of a search list in NDJSON format. Each line
+// has exactly one of the fields set.
+type searchListRecord struct {
+	Header     *SearchListHeader `json:"header,omitempty"`
+	Statement  *LogStatement     `json:"statement,omitempty"`
+	Diagnostic *Diagnostic       `json:"diagnostic,omitempty"`
+}
+
+// WriteJSON writes the search list as a single JSON document.
+func (f *SearchListFile) WriteJSON(w io.Writer) error {
+	file := *f
+	if file.Statements == nil {
+		file.Statements = SearchList{}
+	}
+	return json.NewEncoder(w).Encode(file)
+}
+
+// WriteNDJSON writes the search list as newline-delimited JSON: the header,
+// followed by one line for each statement and each diagnostic. Lists in this
+// format can be read and written without holding them in memory at once.
+func (f *SearchListFile) WriteNDJSON(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if f.Header != nil {
+		if err := enc.Encode(searchListRecord{Header: f.Header}); err != nil {
+			return err
+		}
+	}
+	for _, stmt := range f.Statements {
+		if err := enc.Encode(searchListRecord{Statement: stmt}); err != nil {
+			return err
+		}
+	}
+	for i := range f.Diagnostics {
+		if err := enc.Encode(searchListRecord{Diagnostic: &f.Diagnostics[i]}); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadSearchListFile reads a search list in any of the supported formats: a
+// JSON document with a header, NDJSON, or a bare array of statements.
+func ReadSearchListFile(r io.Reader) (*SearchListFile, error) {
+	statements := SearchList{}
+	file, err := StreamSearchList(r, func(stmt *LogStatement) error {
+		statements = append(statements, stmt)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	file.Statements = statements
+	return file, nil
+}
+
+// StreamSearchList reads a search list like ReadSearchListFile, but calls fn
+// for each statement as it is read instead of collecting them. The returned
+// file contains the header and diagnostics only. If fn returns an error,
+// reading stops and the error is returned.
+func StreamSearchList(r io.Reader, fn func(stmt *LogStatement) error) (*SearchListFile, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	file := &SearchListFile{}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok {
+		case json.Delim('['):
+			// A bare array of statements.
+			for dec.More() {
+				stmt := &LogStatement{}
+				if err := dec.Decode(stmt); err != nil {
+					return nil, err
+				}
+				if err := fn(stmt); err != nil {
+					return nil, err
+				}
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+		case json.Delim('{'):
+			// A search list document or an NDJSON record.
+			if err := readSearchListObject(dec, file, fn); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unexpected %v in search list", tok)
+		}
+	}
+	if file.Header != nil && file.Header.SchemaVersion > SearchListSchemaVersion {
+		return nil, fmt.Errorf("unsupported search list schema version %d (newest supported version is %d)",
+			file.Header.SchemaVersion, SearchListSchemaVersion)
+	}
+	return file, nil
+}
+
+// readSearchListObject reads the fields of an object whose opening brace has
+// been consumed. The statements of a search list document are passed to fn
+// one by one.
+func readSearchListObject(dec *json.Decoder, file *SearchListFile, fn func(stmt *LogStatement) error) error {
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch key, _ := tok.(string); key {
+		case "header":
+			if err := dec.Decode(&file.Header); err != nil {
+				return err
+			}
+		case "statement":
+			stmt := &LogStatement{}
+			if err := dec.Decode(stmt); err != nil {
+				return err
+			}
+			if err := fn(stmt); err != nil {
+				return err
+			}
+		case "diagnostic":
+			var d Diagnostic
+			if err := dec.Decode(&d); err != nil {
+				return err
+			}
+			file.Diagnostics = append(file.Diagnostics, d)
+		case "statements":
+			if tok, err := dec.Token(); err != nil {
+				return err
+			} else if tok == nil {
+				continue
+			} else if tok != json.Delim('[') {
+				return errors.New("statements must be an array")
+			}
+			for dec.More() {
+				stmt := &LogStatement{}
+				if err := dec.Decode(stmt); err != nil {
+					return err
+				}
+				if err := fn(stmt); err != nil {
+					return err
+				}
+			}
+			if _, err := dec.Token(); err != nil {
+				return err
+			}
+		case "diagnostics":
+			var diags []Diagnostic
+			if err := dec.Decode(&diags); err != nil {
+				return err
+			}
+			file.Diagnostics = append(file.Diagnostics, diags...)
+		default:
+			// Skip fields added by newer versions.
+			var skipped json.RawMessage
+			if err := dec.Decode(&skipped); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// Sort sorts the statements in canonical order, by source file, line number,
+// severity and format string.
+func (s SearchList) Sort() {
+	sort.SliceStable(s, func(i, j int) bool {
+		a, b := s[i], s[j]
+		if fa, fb := filepath.ToSlash(a.SourceFile), filepath.ToSlash(b.SourceFile); fa != fb {
+			return fa < fb
+		}
+		if a.LineNumber != b.LineNumber {
+			return a.LineNumber < b.LineNumber
+		}
+		if a.Severity != b.Severity {
+			return a.Severity < b.Severity
+		}
+		if a.FormatString != b.FormatString {
+			return a.FormatString < b.FormatString
+		}
+		return a.Wrapper < b.Wrapper
+	})
+}
+
+// AssignIDs sorts the statements and sets their IDs. An ID is made up of the
+// package and function containing the statement and its position among the
+// statements in that function, such as example.com/pkg/server.(*Server).Run#2,
+// so it only changes if statements are added or removed earlier in the same
+// function.
+func (s SearchList) AssignIDs() {
+	s.Sort()
+	ordinals := map[string]int{}
+	for _, stmt := range s {
+		prefix := stmt.idPrefix()
+		ordinals[prefix]++
+		stmt.ID = fmt.Sprintf("%s#%d", prefix, ordinals[prefix])
+	}
+}
+
+func (s *LogStatement) idPrefix() string {
+	pkg := s.Package
+	if pkg == "" {
+		// Lists written before statements recorded their package.
+		pkg = filepath.ToSlash(filepath.Dir(s.SourceFile))
+	}
+	if s.Function == "" {
+		return pkg
+	}
+	return pkg + "." + strings.ReplaceAll(s.QualifiedFunction(), ", ", ",")
+}
+
+// sortDiagnostics sorts diagnostics by package, file, kind and message.
+func sortDiagnostics(diags []Diagnostic) {
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i], diags[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Message < b.Message
+	})
+}
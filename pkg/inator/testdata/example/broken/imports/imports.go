@@ -0,0 +1,11 @@
+package imports
+
+import (
+	"example.com/example/missing"
+	"k8s.io/klog/v2"
+)
+
+func run() {
+	klog.Info("still searched")
+	missing.Run()
+}
@@ -0,0 +1,7 @@
+package syntax
+
+import "k8s.io/klog/v2"
+
+func broken() {
+	klog.Info("never found"
+}
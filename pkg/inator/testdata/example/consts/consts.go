@@ -0,0 +1,13 @@
+package consts
+
+import "k8s.io/klog/v2"
+
+const (
+	msgOther   = "syncing " + resource
+	levelOther = klog.Level(debugLevel + 1)
+)
+
+const (
+	resource   = "pods"
+	debugLevel = 3
+)
@@ -0,0 +1,9 @@
+package consts
+
+import "k8s.io/klog/v2"
+
+// sync logs with constants declared in consts.go, which are only evaluated
+// by a syntactic search if constants are resolved across files.
+func sync() {
+	klog.V(levelOther).Info(msgOther)
+}
@@ -0,0 +1,7 @@
+module example.com/example
+
+go 1.17
+
+require k8s.io/klog/v2 v2.80.1
+
+require github.com/go-logr/logr v1.2.3 // indirect
@@ -0,0 +1,20 @@
+package helper
+
+import "k8s.io/klog/v2"
+
+// fail logs an error at the location of its caller.
+func fail(err error) {
+	klog.ErrorfDepth(1, "operation failed: %v", err)
+}
+
+// starting logs a message at the location of its caller.
+func starting(name string) {
+	klog.InfoDepth(1, "starting ", name)
+}
+
+func Run(name string, err error) {
+	starting(name)
+	if err != nil {
+		fail(err)
+	}
+}
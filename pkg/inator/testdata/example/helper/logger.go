@@ -0,0 +1,16 @@
+package helper
+
+import "k8s.io/klog/v2"
+
+// Logger logs at the location of the callers of its methods.
+type Logger struct{}
+
+func (l *Logger) Fail(err error) {
+	klog.ErrorfDepth(1, "request failed: %v", err)
+}
+
+// Serve calls the method of the Logger, which a syntactic search cannot
+// resolve.
+func Serve(l *Logger, err error) {
+	l.Fail(err)
+}
@@ -0,0 +1,8 @@
+package main
+
+import "example.com/example/server"
+
+func main() {
+	s := &server.Server{Name: "example"}
+	s.Run(8080)
+}
@@ -0,0 +1,20 @@
+package server
+
+import "k8s.io/klog/v2"
+
+type Server struct {
+	Name string
+}
+
+func (s *Server) Run(port int) {
+	klog.Infof("starting %s on port %d", s.Name, port)
+	klog.V(2).InfoS("listening", "port", port)
+	klog.InfofDepth(0, "serving %s", s.Name)
+	klog.V(4).InfofDepth(0, "serving on port %d", port)
+}
+
+// debug is only found by a type-checked search, since the syntactic search
+// cannot tell that v is a klog.Verbose.
+func debug(v klog.Verbose) {
+	v.Info("debugging")
+}
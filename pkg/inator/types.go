@@ -3,10 +3,10 @@ package inator
 import (
 	"crypto/sha1"
 	"encoding/hex"
+	"fmt"
 	"path/filepath"
 	"strconv"
-
-	"golang.org/x/tools/go/packages"
+	"strings"
 )
 
 type Severity int32
@@ -33,12 +33,158 @@ func (s Severity) String() string {
 	}
 }
 
+// ParseSeverity parses a severity name (info, warning, error or fatal) or
+// the letter used for it in the log header.
+func ParseSeverity(s string) (Severity, error) {
+	switch strings.ToLower(s) {
+	case "info", "i":
+		return SeverityInfo, nil
+	case "warning", "w":
+		return SeverityWarning, nil
+	case "error", "e":
+		return SeverityError, nil
+	case "fatal", "f":
+		return SeverityFatal, nil
+	}
+	return 0, fmt.Errorf("unknown severity %q", s)
+}
+
+// UnmarshalYAML accepts a severity name as well as its numeric value.
+func (s *Severity) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var n int32
+	if err := unmarshal(&n); err == nil {
+		*s = Severity(n)
+		return nil
+	}
+	var name string
+	if err := unmarshal(&name); err != nil {
+		return err
+	}
+	severity, err := ParseSeverity(name)
+	if err != nil {
+		return err
+	}
+	*s = severity
+	return nil
+}
+
 type LogStatement struct {
-	SourceFile   string   `json:"sourceFile"`
-	LineNumber   int      `json:"lineNumber"`
-	Severity     Severity `json:"severity"`
-	Verbosity    *int     `json:"verbosity,omitempty"`
-	FormatString string   `json:"formatString,omitempty"`
+	// ID identifies the statement within a search list (see
+	// SearchList.AssignIDs).
+	ID         string   `json:"id,omitempty"`
+	SourceFile string   `json:"sourceFile"`
+	LineNumber int      `json:"lineNumber"`
+	Severity   Severity `json:"severity"`
+	// OriginalSeverity is the severity the statement is logged with, if it was
+	// reclassified by the severity rule named in SeverityRule.
+	OriginalSeverity *Severity `json:"originalSeverity,omitempty"`
+	SeverityRule     string    `json:"severityRule,omitempty"`
+	Verbosity        *int      `json:"verbosity,omitempty"`
+	// VerbosityExpr is the source text of the V-level, if it was not given as
+	// an integer literal (e.g. a named constant or a variable).
+	VerbosityExpr string `json:"verbosityExpr,omitempty"`
+	// VerbosityUnknown is true if the statement is V-gated, but the level
+	// could not be evaluated at search time. Verbosity is nil in that case.
+	// Without --type-check, levels given by constants of other packages are
+	// never evaluated.
+	VerbosityUnknown bool `json:"verbosityUnknown,omitempty"`
+	// FormatString is the format string (or message) as a quoted Go string.
+	// If DynamicFormat is true, it is not constant, and FormatString contains
+	// the source text of the expression instead, e.g. fmt.Sprintf(...).
+	// Without --type-check, constants of other packages are reported as
+	// dynamic formats.
+	FormatString  string `json:"formatString,omitempty"`
+	DynamicFormat bool   `json:"dynamicFormat,omitempty"`
+	// MessageStyle is how the logging function formats the message.
+	MessageStyle MessageStyle `json:"messageStyle,omitempty"`
+	// KeysAndValues contains the key/value pairs of structured calls (InfoS,
+	// ErrorS, and logr calls), in the order they were passed.
+	KeysAndValues []KeyValue `json:"keysAndValues,omitempty"`
+	// Contextual is true if the statement is a call to Info or Error on a
+	// logr.Logger (e.g. from klog.FromContext) rather than a klog function.
+	Contextual bool `json:"contextual,omitempty"`
+	// Function is the name of the function containing the statement, or the
+	// name of the package-level variable whose initializer contains it.
+	Function string `json:"function,omitempty"`
+	// Receiver is the receiver type of the method containing the statement,
+	// such as *Server.
+	Receiver string `json:"receiver,omitempty"`
+	// EndLineNumber and EndColumn are the position just after the closing
+	// parenthesis of the call.
+	EndLineNumber int `json:"endLineNumber,omitempty"`
+	EndColumn     int `json:"endColumn,omitempty"`
+	// Arguments contains the source text of each argument of the call.
+	Arguments []string `json:"arguments,omitempty"`
+	// Risks lists the arguments which may contain sensitive data, such as
+	// passwords or tokens.
+	Risks []Risk `json:"risks,omitempty"`
+	// Wrapper is set if the statement is logged by a helper function which
+	// calls one of the klog *Depth functions on behalf of its caller. It
+	// contains the import path and name of the helper called at this
+	// location (in the form of types.Func.FullName for methods, such as
+	// (*example.com/log.Logger).Errorf), and the format string and severity are those of the klog
+	// call inside the helper. The end position and arguments are those of
+	// the call to the helper.
+	Wrapper string `json:"wrapper,omitempty"`
+	// BuildConfigs lists the build configurations the statement was found in,
+	// if the search was run over several configurations.
+	BuildConfigs []string `json:"buildConfigs,omitempty"`
+	// Module and ModuleVersion identify the module containing the statement.
+	// ModuleVersion is empty for the main module.
+	Module        string `json:"module,omitempty"`
+	ModuleVersion string `json:"moduleVersion,omitempty"`
+	// Package is the import path of the package containing the statement.
+	Package string `json:"package,omitempty"`
+	// Revision is the VCS revision the main module was checked out at, if the
+	// statement is in the main module and it could be determined.
+	Revision string `json:"revision,omitempty"`
+}
+
+// KeyValue is a key/value pair passed to a structured logging call.
+type KeyValue struct {
+	// Key is the name of the key. If the key is not a constant string, it
+	// contains the source text of the key expression and DynamicKey is true.
+	Key        string `json:"key"`
+	DynamicKey bool   `json:"dynamicKey,omitempty"`
+	// Value is the source text of the value expression. It is empty if the
+	// key has no value.
+	Value string `json:"value,omitempty"`
+}
+
+// SearchListHeader describes how a search list was produced, so that lists
+// from different releases or searches can be told apart.
+type SearchListHeader struct {
+	// SchemaVersion is the version of the search list format.
+	SchemaVersion int               `json:"schemaVersion"`
+	GoVersion     string            `json:"goVersion"`
+	Patterns      []string          `json:"patterns"`
+	Options       SearchListOptions `json:"options"`
+}
+
+// SearchListOptions records the search options in a search list header.
+type SearchListOptions struct {
+	BuildTags        []string       `json:"buildTags,omitempty"`
+	GOOS             string         `json:"goos,omitempty"`
+	GOARCH           string         `json:"goarch,omitempty"`
+	ModFlag          string         `json:"modFlag,omitempty"`
+	ExcludeModules   []string       `json:"excludeModules,omitempty"`
+	ExcludeFilenames []string       `json:"excludeFilenames,omitempty"`
+	ErrorKeywords    []string       `json:"errorKeywords,omitempty"`
+	TypeCheck        bool           `json:"typeCheck,omitempty"`
+	BuildConfigs     []string       `json:"buildConfigs,omitempty"`
+	Tests            bool           `json:"tests,omitempty"`
+	Registries       []*Registry    `json:"registries,omitempty"`
+	SeverityRules    []SeverityRule `json:"severityRules,omitempty"`
+}
+
+// SearchListFile is the search list written by the search command, either as
+// a JSON document or in NDJSON format. Older versions wrote the statements as
+// a bare array, without a header.
+type SearchListFile struct {
+	Header     *SearchListHeader `json:"header,omitempty"`
+	Statements SearchList        `json:"statements"`
+	// Diagnostics reported by the search which produced the list.
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
 }
 
 type ParsedLog struct {
@@ -48,91 +194,68 @@ type ParsedLog struct {
 	Message    string `json:"message"`
 }
 
+func (s *LogStatement) addBuildConfig(config string) {
+	for _, c := range s.BuildConfigs {
+		if c == config {
+			return
+		}
+	}
+	s.BuildConfigs = append(s.BuildConfigs, config)
+}
+
+// QualifiedFunction returns the name of the function containing the
+// statement, qualified with the receiver type for methods, such as
+// (*Server).Run.
+func (s LogStatement) QualifiedFunction() string {
+	switch {
+	case s.Receiver == "":
+		return s.Function
+	case strings.HasPrefix(s.Receiver, "*"):
+		return "(" + s.Receiver + ")." + s.Function
+	default:
+		return s.Receiver + "." + s.Function
+	}
+}
+
 func (s LogStatement) ShortSourceFile() string {
 	return filepath.Join(filepath.Base(filepath.Dir(s.SourceFile)), filepath.Base(s.SourceFile))
 }
 
+// LoggedSeverity returns the severity klog writes into the header of the
+// statement's logs, which is its original severity if a severity rule
+// reclassified it.
+func (s LogStatement) LoggedSeverity() Severity {
+	if s.OriginalSeverity != nil {
+		return *s.OriginalSeverity
+	}
+	return s.Severity
+}
+
 func (s LogStatement) Fingerprint() string {
 	// To compute the fingerprint, hash the source file name with its immediate
-	// parent directory, the line number, and severity.
+	// parent directory, the line number, and the severity in the log header.
 	h := sha1.New()
 	h.Write([]byte(s.ShortSourceFile()))
 	h.Write([]byte(strconv.Itoa(s.LineNumber)))
-	h.Write([]byte(strconv.Itoa(int(s.Severity))))
+	h.Write([]byte(strconv.Itoa(int(s.LoggedSeverity()))))
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// ShortSourceFile returns the file name and its immediate parent directory,
+// even if the log carries a longer path.
+func (s ParsedLog) ShortSourceFile() string {
+	if i := strings.LastIndexByte(s.SourceFile, '/'); i > 0 {
+		if j := strings.LastIndexByte(s.SourceFile[:i], '/'); j >= 0 {
+			return s.SourceFile[j+1:]
+		}
+	}
+	return s.SourceFile
+}
+
 func (s ParsedLog) Fingerprint() string {
 	h := sha1.New()
-	h.Write([]byte(s.SourceFile))
+	h.Write([]byte(s.ShortSourceFile()))
 	h.Write([]byte(strconv.Itoa(s.LineNumber)))
 	h.Write([]byte(strconv.Itoa(int(s.Severity))))
 	return hex.EncodeToString(h.Sum(nil))
 }
-
-type internalPackage struct {
-	Dir           string           // directory containing package sources
-	ImportPath    string           // import path of package in dir
-	ImportComment string           // path in import comment on package statement
-	Name          string           // package name
-	Doc           string           // package documentation string
-	Target        string           // install path
-	Shlib         string           // the shared library that contains this package (only set when -linkshared)
-	Goroot        bool             // is this package in the Go root?
-	Standard      bool             // is this package part of the standard Go library?
-	Stale         bool             // would 'go install' do anything for this package?
-	StaleReason   string           // explanation for Stale==true
-	Root          string           // Go root or Go path dir containing this package
-	ConflictDir   string           // this directory shadows Dir in $GOPATH
-	BinaryOnly    bool             // binary-only package (no longer supported)
-	ForTest       string           // package is only for use in named test
-	Export        string           // file containing export data (when using -export)
-	BuildID       string           // build ID of the compiled package (when using -export)
-	Module        *packages.Module // info about package's containing module, if any (can be nil)
-	Match         []string         // command-line patterns matching this package
-	DepOnly       bool             // package is only a dependency, not explicitly listed
-
-	// Source files
-	GoFiles           []string // .go source files (excluding CgoFiles, TestGoFiles, XTestGoFiles)
-	CgoFiles          []string // .go source files that import "C"
-	CompiledGoFiles   []string // .go files presented to compiler (when using -compiled)
-	IgnoredGoFiles    []string // .go source files ignored due to build constraints
-	IgnoredOtherFiles []string // non-.go source files ignored due to build constraints
-	CFiles            []string // .c source files
-	CXXFiles          []string // .cc, .cxx and .cpp source files
-	MFiles            []string // .m source files
-	HFiles            []string // .h, .hh, .hpp and .hxx source files
-	FFiles            []string // .f, .F, .for and .f90 Fortran source files
-	SFiles            []string // .s source files
-	SwigFiles         []string // .swig files
-	SwigCXXFiles      []string // .swigcxx files
-	SysoFiles         []string // .syso object files to add to archive
-	TestGoFiles       []string // _test.go files in package
-	XTestGoFiles      []string // _test.go files outside package
-
-	// Embedded files
-	EmbedPatterns      []string // //go:embed patterns
-	EmbedFiles         []string // files matched by EmbedPatterns
-	TestEmbedPatterns  []string // //go:embed patterns in TestGoFiles
-	TestEmbedFiles     []string // files matched by TestEmbedPatterns
-	XTestEmbedPatterns []string // //go:embed patterns in XTestGoFiles
-	XTestEmbedFiles    []string // files matched by XTestEmbedPatterns
-
-	// Cgo directives
-	CgoCFLAGS    []string // cgo: flags for C compiler
-	CgoCPPFLAGS  []string // cgo: flags for C preprocessor
-	CgoCXXFLAGS  []string // cgo: flags for C++ compiler
-	CgoFFLAGS    []string // cgo: flags for Fortran compiler
-	CgoLDFLAGS   []string // cgo: flags for linker
-	CgoPkgConfig []string // cgo: pkg-config names
-
-	// Dependency information
-	Imports      []string          // import paths used by this package
-	ImportMap    map[string]string // map from source import to ImportPath (identity entries omitted)
-	Deps         []string          // all (recursively) imported dependencies
-	TestImports  []string          // imports from TestGoFiles
-	XTestImports []string          // imports from XTestGoFiles
-
-	// Error information
-	Incomplete bool // this package or a dependency has an error
-}
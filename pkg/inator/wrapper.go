@@ -0,0 +1,98 @@
+package inator
+
+import (
+	"go/ast"
+	"strings"
+)
+
+// A wrapper is a log statement inside a helper function, such as
+//
+//	func logErr(err error) { klog.ErrorDepth(1, err) }
+//
+// which passes a constant depth to one of the klog *Depth functions. klog
+// reports the file and line of a caller further up the stack for these
+// statements, so they are never seen at their own location. Instead, a
+// synthetic statement is recorded at every call site of the helper.
+//
+// Calls to methods can only be resolved with type information, so the call
+// sites of helper methods are only found by type-checked searches.
+type wrapper struct {
+	// The function containing the statement, as returned by wrapperKey.
+	key string
+	// The number of stack frames above key the statement is attributed to.
+	depth int
+	stmt  *LogStatement
+}
+
+// expandWrappers looks for calls to the wrapper functions in the given files
+// and returns a copy of the wrapped statement for each call site. Calls made
+// with a remaining depth greater than 1 turn the calling function into a
+// wrapper as well.
+func expandWrappers(wrappers []wrapper, files []*searchedFile) []*LogStatement {
+	byKey := map[string][]wrapper{}
+	for _, w := range wrappers {
+		byKey[w.key] = append(byKey[w.key], w)
+	}
+	var statements []*LogStatement
+	for len(byKey) > 0 {
+		next := map[string][]wrapper{}
+		for _, sf := range files {
+			if sf.ast == nil && !sf.loadIfCalling(byKey) {
+				continue
+			}
+			forEachDecl(sf.ast, func(node ast.Node, enclosing, receiver string, callable bool) {
+				inspectCalls(node, func(call *ast.CallExpr, direct bool) bool {
+					ws, ok := byKey[sf.resolver.calleeKey(call)]
+					if !ok {
+						return true
+					}
+					for _, w := range ws {
+						if w.depth > 1 {
+							if callable && direct {
+								key := wrapperKey(sf.pkg.PkgPath, enclosing, receiver)
+								next[key] = append(next[key], wrapper{
+									key:   key,
+									depth: w.depth - 1,
+									stmt:  w.stmt,
+								})
+							}
+							continue
+						}
+						stmt := *w.stmt
+						stmt.SourceFile = sf.relPath
+						stmt.LineNumber = sf.fset.Position(call.Pos()).Line
+						stmt.Function = enclosing
+						stmt.Receiver = receiver
+						setCallContext(&stmt, sf, call)
+						stmt.Wrapper = w.key
+						sf.origin.apply(&stmt)
+						statements = append(statements, &stmt)
+					}
+					return true
+				})
+			})
+		}
+		byKey = next
+	}
+	return statements
+}
+
+// wrapperKey returns the key of the function or method with the given name
+// and receiver type (as returned by forEachDecl) declared in the package, in
+// the form of types.Func.FullName: the import path and name of functions,
+// such as example.com/log.errorf, or the qualified receiver type and name of
+// methods, such as (*example.com/log.Logger).Errorf. Type parameters of the
+// receiver are left out.
+func wrapperKey(pkgPath, name, receiver string) string {
+	if receiver == "" {
+		return pkgPath + "." + name
+	}
+	if i := strings.IndexByte(receiver, '['); i >= 0 {
+		receiver = receiver[:i]
+	}
+	ptr := ""
+	if strings.HasPrefix(receiver, "*") {
+		ptr, receiver = "*", receiver[1:]
+	}
+	return "(" + ptr + pkgPath + "." + receiver + ")." + name
+}
@@ -0,0 +1,55 @@
+package inator_test
+
+import (
+	"testing"
+
+	"github.com/kralicky/klog-inator/pkg/inator"
+)
+
+func TestExpandWrappers(t *testing.T) {
+	for _, typeCheck := range []bool{false, true} {
+		result := searchTestdata(t, "example", []string{"./helper"}, inator.WithTypeCheck(typeCheck))
+		expected := []inator.LogStatement{
+			{
+				SourceFile:   "helper/helper.go",
+				LineNumber:   16,
+				Severity:     inator.SeverityInfo,
+				FormatString: `"starting "`,
+				Wrapper:      "example.com/example/helper.starting",
+				Function:     "Run",
+			},
+			{
+				SourceFile:   "helper/helper.go",
+				LineNumber:   18,
+				Severity:     inator.SeverityError,
+				FormatString: `"operation failed: %v"`,
+				Wrapper:      "example.com/example/helper.fail",
+				Function:     "Run",
+			},
+		}
+		if typeCheck {
+			// methods are only resolved with type information, and the
+			// statement in the method is never reported at its own line
+			expected = append(expected, inator.LogStatement{
+				SourceFile:   "helper/logger.go",
+				LineNumber:   15,
+				Severity:     inator.SeverityError,
+				FormatString: `"request failed: %v"`,
+				Wrapper:      "(*example.com/example/helper.Logger).Fail",
+				Function:     "Serve",
+			})
+		}
+		if len(result.Statements) != len(expected) {
+			t.Fatalf("type check %v: expected %d statements, got %d: %+v", typeCheck, len(expected), len(result.Statements), result.Statements)
+		}
+		for i, stmt := range result.Statements {
+			want := expected[i]
+			if stmt.SourceFile != want.SourceFile || stmt.LineNumber != want.LineNumber || stmt.Severity != want.Severity ||
+				stmt.FormatString != want.FormatString || stmt.Wrapper != want.Wrapper || stmt.Function != want.Function {
+				t.Errorf("type check %v: got %s:%d %s %s in %s (wrapper %s), want %s:%d %s %s in %s (wrapper %s)", typeCheck,
+					stmt.SourceFile, stmt.LineNumber, stmt.Severity, stmt.FormatString, stmt.Function, stmt.Wrapper,
+					want.SourceFile, want.LineNumber, want.Severity, want.FormatString, want.Function, want.Wrapper)
+			}
+		}
+	}
+}